@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -18,6 +19,15 @@ var (
 	configPath string
 )
 
+const (
+	DebridSelectionPriority    = "priority"     // Strict priority order
+	DebridSelectionWeighted    = "weighted"     // Weighted round-robin
+	DebridSelectionLeastFailed = "least_failed" // Least recently failed first
+)
+
+// SupportedDebrids lists the provider names accepted in Debrid.Name
+var SupportedDebrids = []string{"realdebrid", "torbox", "debridlink", "alldebrid", "premiumize", "offcloud", "mock"}
+
 type Debrid struct {
 	Name             string   `json:"name,omitempty"`
 	APIKey           string   `json:"api_key,omitempty"`
@@ -29,6 +39,19 @@ type Debrid struct {
 	Proxy            string   `json:"proxy,omitempty"`
 	AddSamples       bool     `json:"add_samples,omitempty"`
 
+	// Routing
+	Priority    int      `json:"priority,omitempty"`     // Lower is tried first
+	Weight      int      `json:"weight,omitempty"`       // Share of torrents when using weighted selection
+	AllowedArrs []string `json:"allowed_arrs,omitempty"` // Only accept torrents from these arrs
+	DeniedArrs  []string `json:"denied_arrs,omitempty"`  // Never accept torrents from these arrs
+
+	// Mapping describes the API of a provider without a dedicated client
+	Mapping *DebridMapping `json:"mapping,omitempty"`
+	// Mock configures the in-memory mock debrid
+	Mock *MockDebrid `json:"mock,omitempty"`
+	// FileSelection chooses the files to download, for providers that support it
+	FileSelection *FileSelection `json:"file_selection,omitempty"`
+
 	UseWebDav bool `json:"use_webdav,omitempty"`
 	WebDav
 }
@@ -42,6 +65,7 @@ type QBitTorrent struct {
 	RefreshInterval int      `json:"refresh_interval,omitempty"`
 	SkipPreCache    bool     `json:"skip_pre_cache,omitempty"`
 	MaxDownloads    int      `json:"max_downloads,omitempty"`
+	Strm            bool     `json:"strm,omitempty"` // write .strm files instead of symlinks for WebDAV debrids
 }
 
 type Arr struct {
@@ -51,6 +75,9 @@ type Arr struct {
 	Cleanup          bool   `json:"cleanup,omitempty"`
 	SkipRepair       bool   `json:"skip_repair,omitempty"`
 	DownloadUncached *bool  `json:"download_uncached,omitempty"`
+	CheckCached      *bool  `json:"check_cached,omitempty"` // Overrides the debrid's check_cached
+
+	FileSelection *FileSelection `json:"file_selection,omitempty"`
 }
 
 type Repair struct {
@@ -88,6 +115,9 @@ type Config struct {
 	UseAuth        bool        `json:"use_auth,omitempty"`
 	Auth           *Auth       `json:"-"`
 	DiscordWebhook string      `json:"discord_webhook_url,omitempty"`
+
+	// Debrid routing
+	DebridSelection string `json:"debrid_selection,omitempty"` // priority, weighted or least_failed
 }
 
 func (c *Config) JsonFile() string {
@@ -130,17 +160,79 @@ func validateDebrids(debrids []Debrid) error {
 
 	for _, debrid := range debrids {
 		// Basic field validation
-		if debrid.APIKey == "" {
+		if debrid.Name == "" {
+			return errors.New("debrid name is required")
+		}
+		if debrid.Mapping != nil {
+			if err := debrid.Mapping.Validate(); err != nil {
+				return fmt.Errorf("debrid %s: %w", debrid.Name, err)
+			}
+		} else if !slices.Contains(SupportedDebrids, debrid.Name) {
+			return fmt.Errorf("unknown debrid provider: %s", debrid.Name)
+		}
+		if debrid.APIKey == "" && debrid.Name != "mock" {
 			return errors.New("debrid api key is required")
 		}
 		if debrid.Folder == "" {
 			return errors.New("debrid folder is required")
 		}
+		if debrid.Mock != nil {
+			for _, rate := range []float64{debrid.Mock.HosterUnavailableRate, debrid.Mock.TrafficExceededRate} {
+				if rate < 0 || rate > 1 {
+					return fmt.Errorf("debrid %s failure rates must be between 0 and 1", debrid.Name)
+				}
+			}
+		}
+		if debrid.FileSelection != nil {
+			if err := debrid.FileSelection.Validate(); err != nil {
+				return fmt.Errorf("debrid %s: %w", debrid.Name, err)
+			}
+		}
+		if debrid.Weight < 0 {
+			return fmt.Errorf("debrid %s weight must be positive", debrid.Name)
+		}
+		if err := validateDirectories(debrid.Directories); err != nil {
+			return fmt.Errorf("debrid %s: %w", debrid.Name, err)
+		}
+		for _, a := range debrid.AllowedArrs {
+			if slices.Contains(debrid.DeniedArrs, a) {
+				return fmt.Errorf("debrid %s both allows and denies arr %s", debrid.Name, a)
+			}
+		}
 	}
 
 	return nil
 }
 
+func validateDirectories(directories map[string]WebdavDirectories) error {
+	for name, dir := range directories {
+		if _, err := dir.Compile(); err != nil {
+			return fmt.Errorf("folder %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateArrs(arrs []Arr) error {
+	for _, a := range arrs {
+		if a.FileSelection != nil {
+			if err := a.FileSelection.Validate(); err != nil {
+				return fmt.Errorf("arr %s: %w", a.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateDebridSelection(selection string) error {
+	switch selection {
+	case "", DebridSelectionPriority, DebridSelectionWeighted, DebridSelectionLeastFailed:
+		return nil
+	default:
+		return fmt.Errorf("invalid debrid selection: %s", selection)
+	}
+}
+
 func validateQbitTorrent(config *QBitTorrent) error {
 	if config.DownloadFolder == "" {
 		return errors.New("qbittorent download folder is required")
@@ -168,6 +260,18 @@ func ValidateConfig(config *Config) error {
 		return err
 	}
 
+	if err := validateDebridSelection(config.DebridSelection); err != nil {
+		return err
+	}
+
+	if err := validateDirectories(config.WebDav.Directories); err != nil {
+		return fmt.Errorf("webdav: %w", err)
+	}
+
+	if err := validateArrs(config.Arrs); err != nil {
+		return err
+	}
+
 	if err := validateQbitTorrent(&config.QBitTorrent); err != nil {
 		return err
 	}
@@ -275,6 +379,10 @@ func (c *Config) updateDebrid(d Debrid) Debrid {
 		d.DownloadAPIKeys = append(d.DownloadAPIKeys, d.APIKey)
 	}
 
+	if d.Weight == 0 {
+		d.Weight = 1
+	}
+
 	if !d.UseWebDav {
 		return d
 	}
@@ -282,6 +390,9 @@ func (c *Config) updateDebrid(d Debrid) Debrid {
 	if d.TorrentsRefreshInterval == "" {
 		d.TorrentsRefreshInterval = cmp.Or(c.WebDav.TorrentsRefreshInterval, "15s") // 15 seconds
 	}
+	if d.TorrentsFullRefreshInterval == "" {
+		d.TorrentsFullRefreshInterval = cmp.Or(c.WebDav.TorrentsFullRefreshInterval, "1h") // 1 hour
+	}
 	if d.WebDav.DownloadLinksRefreshInterval == "" {
 		d.DownloadLinksRefreshInterval = cmp.Or(c.WebDav.DownloadLinksRefreshInterval, "40m") // 40 minutes
 	}
@@ -291,9 +402,59 @@ func (c *Config) updateDebrid(d Debrid) Debrid {
 	if d.FolderNaming == "" {
 		d.FolderNaming = cmp.Or(c.WebDav.FolderNaming, "original_no_ext")
 	}
+	d.MediaLibrary = d.MediaLibrary || c.WebDav.MediaLibrary
+	if d.StrmURL == "" {
+		d.StrmURL = c.WebDav.StrmURL
+	}
 	if d.AutoExpireLinksAfter == "" {
 		d.AutoExpireLinksAfter = cmp.Or(c.WebDav.AutoExpireLinksAfter, "3d") // 2 days
 	}
+	if d.StreamChunkSize == "" {
+		d.StreamChunkSize = cmp.Or(c.WebDav.StreamChunkSize, "8MB")
+	}
+	if d.StreamReadAhead == "" {
+		d.StreamReadAhead = cmp.Or(c.WebDav.StreamReadAhead, "16MB")
+	}
+	if d.StreamCacheSize == "" {
+		d.StreamCacheSize = cmp.Or(c.WebDav.StreamCacheSize, "256MB")
+	}
+	if d.StreamDiskCacheSize == "" {
+		d.StreamDiskCacheSize = cmp.Or(c.WebDav.StreamDiskCacheSize, "0")
+	}
+	if d.LinkPrefetchRate == 0 {
+		d.LinkPrefetchRate = cmp.Or(c.WebDav.LinkPrefetchRate, 10)
+	}
+	if d.LinkPrefetchRecent == "" {
+		d.LinkPrefetchRecent = cmp.Or(c.WebDav.LinkPrefetchRecent, "24h")
+	}
+	if d.LinkPrefetchNew == "" {
+		d.LinkPrefetchNew = cmp.Or(c.WebDav.LinkPrefetchNew, "6h")
+	}
+	if d.RepairWorkers == 0 {
+		d.RepairWorkers = cmp.Or(c.WebDav.RepairWorkers, 2)
+	}
+	if d.ReinsertMaxAttempts == 0 {
+		d.ReinsertMaxAttempts = cmp.Or(c.WebDav.ReinsertMaxAttempts, 5)
+	}
+	if d.ReinsertBackoff == "" {
+		d.ReinsertBackoff = cmp.Or(c.WebDav.ReinsertBackoff, "10m,1h,6h,24h")
+	}
+	if d.ReinsertFinalAction == "" {
+		d.ReinsertFinalAction = cmp.Or(c.WebDav.ReinsertFinalAction, "bad")
+	}
+	d.ReinsertFallback = d.ReinsertFallback || c.WebDav.ReinsertFallback
+	if d.HealthScanInterval == "" {
+		d.HealthScanInterval = cmp.Or(c.WebDav.HealthScanInterval, "24h")
+	}
+	if d.HealthScanBatchSize == 0 {
+		d.HealthScanBatchSize = cmp.Or(c.WebDav.HealthScanBatchSize, 20)
+	}
+	if d.TrashRetention == "" {
+		d.TrashRetention = cmp.Or(c.WebDav.TrashRetention, "720h") // 30 days
+	}
+	if d.TrashMaxSize == "" {
+		d.TrashMaxSize = cmp.Or(c.WebDav.TrashMaxSize, "100MB")
+	}
 
 	// Merge debrid specified directories with global directories
 
@@ -323,6 +484,8 @@ func (c *Config) setDefaults() {
 		c.AllowedExt = getDefaultExtensions()
 	}
 
+	c.DebridSelection = cmp.Or(c.DebridSelection, DebridSelectionPriority)
+
 	c.Port = cmp.Or(c.Port, c.QBitTorrent.Port)
 
 	if c.URLBase == "" {
@@ -0,0 +1,492 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DirectoryFilter is the compiled filter of a WebDAV custom folder
+type DirectoryFilter struct {
+	root filterNode
+}
+
+// FilterSubject is what a directory filter is matched against
+type FilterSubject struct {
+	Name    string   // folder name of the torrent
+	Files   []string // names of the files in the torrent
+	Arr     string
+	Debrid  string
+	Bad     bool
+	Size    int64
+	AddedOn time.Time
+}
+
+// Match reports whether a torrent belongs in the folder
+func (f *DirectoryFilter) Match(s FilterSubject, now time.Time) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+	return f.root.match(&s, now)
+}
+
+type filterNode interface {
+	match(s *FilterSubject, now time.Time) bool
+}
+
+type andNode []filterNode
+
+func (n andNode) match(s *FilterSubject, now time.Time) bool {
+	for _, c := range n {
+		if !c.match(s, now) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []filterNode
+
+func (n orNode) match(s *FilterSubject, now time.Time) bool {
+	for _, c := range n {
+		if c.match(s, now) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	node filterNode
+}
+
+func (n notNode) match(s *FilterSubject, now time.Time) bool {
+	return !n.node.match(s, now)
+}
+
+type filterField int
+
+const (
+	fieldName filterField = iota
+	fieldFile
+	fieldArr
+	fieldDebrid
+	fieldBad
+	fieldFiles
+	fieldSize
+	fieldAdded
+)
+
+var filterFields = map[string]filterField{
+	"name":   fieldName,
+	"file":   fieldFile,
+	"arr":    fieldArr,
+	"debrid": fieldDebrid,
+	"bad":    fieldBad,
+	"files":  fieldFiles,
+	"size":   fieldSize,
+	"added":  fieldAdded,
+}
+
+var (
+	stringOps  = []string{"=", "!=", "contains", "starts_with", "ends_with", "matches"}
+	numberOps  = []string{"=", "!=", "<", "<=", ">", ">="}
+	booleanOps = []string{"=", "!="}
+)
+
+// clause compares a field of the subject with a value
+type clause struct {
+	field  filterField
+	op     string
+	value  string
+	regex  *regexp.Regexp
+	number int64         // files and size
+	age    time.Duration // added
+	flag   bool          // bad
+}
+
+func (c *clause) match(s *FilterSubject, now time.Time) bool {
+	switch c.field {
+	case fieldName:
+		return c.matchString(s.Name)
+	case fieldFile:
+		// Any file of the torrent
+		for _, f := range s.Files {
+			if c.matchString(f) {
+				return true
+			}
+		}
+		return false
+	case fieldArr:
+		return c.matchString(s.Arr)
+	case fieldDebrid:
+		return c.matchString(s.Debrid)
+	case fieldBad:
+		return (s.Bad == c.flag) == (c.op == "=")
+	case fieldFiles:
+		return compareNumbers(int64(len(s.Files)), c.op, c.number)
+	case fieldSize:
+		return compareNumbers(s.Size, c.op, c.number)
+	case fieldAdded:
+		// added < 24h reads as added less than 24 hours ago
+		return compareNumbers(int64(now.Sub(s.AddedOn)), c.op, int64(c.age))
+	}
+	return false
+}
+
+func (c *clause) matchString(v string) bool {
+	if c.op == "matches" {
+		return c.regex.MatchString(v)
+	}
+	v = strings.ToLower(v)
+	switch c.op {
+	case "=":
+		return v == c.value
+	case "!=":
+		return v != c.value
+	case "contains":
+		return strings.Contains(v, c.value)
+	case "starts_with":
+		return strings.HasPrefix(v, c.value)
+	case "ends_with":
+		return strings.HasSuffix(v, c.value)
+	}
+	return false
+}
+
+func compareNumbers(a int64, op string, b int64) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func newClause(field, op, value string) (*clause, error) {
+	f, ok := filterFields[strings.ToLower(field)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	op = strings.ToLower(op)
+	c := &clause{field: f, op: op, value: strings.ToLower(value)}
+	switch f {
+	case fieldName, fieldFile, fieldArr, fieldDebrid:
+		if !slices.Contains(stringOps, op) {
+			return nil, fmt.Errorf("%s doesn't support %s", field, op)
+		}
+		if op == "matches" {
+			re, err := regexp.Compile("(?i)" + value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", value, err)
+			}
+			c.regex = re
+		}
+	case fieldBad:
+		if !slices.Contains(booleanOps, op) {
+			return nil, fmt.Errorf("%s doesn't support %s", field, op)
+		}
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", value)
+		}
+		c.flag = flag
+	case fieldFiles:
+		if !slices.Contains(numberOps, op) {
+			return nil, fmt.Errorf("%s doesn't support %s", field, op)
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		c.number = n
+	case fieldSize:
+		if !slices.Contains(numberOps, op) {
+			return nil, fmt.Errorf("%s doesn't support %s", field, op)
+		}
+		n, err := ParseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", value)
+		}
+		c.number = n
+	case fieldAdded:
+		if !slices.Contains(numberOps, op) {
+			return nil, fmt.Errorf("%s doesn't support %s", field, op)
+		}
+		age, err := parseAge(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q", value)
+		}
+		c.age = age
+	}
+	return c, nil
+}
+
+// parseAge parses a duration, also accepting days, e.g. 7d
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// legacyFilters maps the filter types of the filters map to clauses
+var legacyFilters = map[string]struct {
+	field, op string
+	not       bool
+}{
+	"include":         {"name", "contains", false},
+	"exclude":         {"name", "contains", true},
+	"starts_with":     {"name", "starts_with", false},
+	"not_starts_with": {"name", "starts_with", true},
+	"ends_with":       {"name", "ends_with", false},
+	"not_ends_with":   {"name", "ends_with", true},
+	"regex":           {"name", "matches", false},
+	"not_regex":       {"name", "matches", true},
+	"exact_match":     {"name", "=", false},
+	"not_exact_match": {"name", "!=", false},
+	"size_gt":         {"size", ">", false},
+	"size_lt":         {"size", "<", false},
+	"last_added":      {"added", "<", false},
+}
+
+// Compile compiles the filters map and the filter expression of a folder. A torrent must match both.
+func (d WebdavDirectories) Compile() (*DirectoryFilter, error) {
+	nodes := make(andNode, 0, len(d.Filters)+1)
+	for filterType, value := range d.Filters {
+		legacy, ok := legacyFilters[filterType]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q", filterType)
+		}
+		c, err := newClause(legacy.field, legacy.op, value)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s: %w", filterType, err)
+		}
+		if legacy.not {
+			nodes = append(nodes, notNode{c})
+		} else {
+			nodes = append(nodes, c)
+		}
+	}
+	if strings.TrimSpace(d.Filter) != "" {
+		root, err := ParseDirectoryFilter(d.Filter)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, root.root)
+	}
+	return &DirectoryFilter{root: nodes}, nil
+}
+
+// ParseDirectoryFilter parses a filter expression, e.g.
+//
+//	(name contains 1080p OR name contains 2160p) AND NOT bad = true AND file ends_with .mkv
+//
+// Clauses are <field> <op> <value>, grouped with AND, OR, NOT and parentheses. Values with spaces are quoted.
+func ParseDirectoryFilter(expr string) (*DirectoryFilter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &DirectoryFilter{root: root}, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, filterToken{text: string(r), pos: i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == r {
+					i++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated quote at %d", start)
+			}
+			i++
+			tokens = append(tokens, filterToken{text: sb.String(), quoted: true, pos: start})
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected ! at %d", start)
+			}
+			tokens = append(tokens, filterToken{text: op, pos: start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()=!<>\"'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[start:i]), pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	t, ok := p.peek()
+	if !ok {
+		return t, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return t, nil
+}
+
+// keyword reports whether the next token is the unquoted keyword kw, and consumes it
+func (p *filterParser) keyword(kw string) bool {
+	t, ok := p.peek()
+	if ok && !t.quoted && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{left}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return nodes, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := andNode{left}
+	for p.keyword("AND") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return nodes, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.keyword("NOT") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !t.quoted && t.text == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.next()
+		if err != nil || closing.quoted || closing.text != ")" {
+			return nil, fmt.Errorf("missing ) for ( at %d", t.pos)
+		}
+		return node, nil
+	}
+	if t.quoted || t.text == ")" {
+		return nil, fmt.Errorf("expected a field at %d, got %q", t.pos, t.text)
+	}
+
+	// bad alone reads as bad = true
+	if strings.EqualFold(t.text, "bad") {
+		if next, ok := p.peek(); !ok || next.quoted || !slices.Contains(booleanOps, next.text) {
+			return newClause(t.text, "=", "true")
+		}
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, fmt.Errorf("expected an operator after %s", t.text)
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, fmt.Errorf("expected a value after %s %s", t.text, op.text)
+	}
+	if !value.quoted && (value.text == "(" || value.text == ")") {
+		return nil, fmt.Errorf("expected a value at %d, got %q", value.pos, value.text)
+	}
+	c, err := newClause(t.text, op.text, value.text)
+	if err != nil {
+		return nil, fmt.Errorf("at %d: %w", t.pos, err)
+	}
+	return c, nil
+}
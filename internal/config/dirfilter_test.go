@@ -0,0 +1,205 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+var filterNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// filterMovie is the subject the filter tests match against
+var filterMovie = FilterSubject{
+	Name:    "The.Movie.2020.1080p.WEB-DL",
+	Files:   []string{"The.Movie.2020.1080p.WEB-DL.mkv", "sample.mkv", "The.Movie.srt"},
+	Arr:     "radarr",
+	Debrid:  "realdebrid",
+	Bad:     false,
+	Size:    4 * 1024 * 1024 * 1024,
+	AddedOn: filterNow.Add(-3 * time.Hour),
+}
+
+func TestDirectoryFilterMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		// name and the other string fields, matched case-insensitively
+		{"name equals", `name = the.movie.2020.1080p.web-dl`, true},
+		{"name not equals", `name != the.movie.2020.1080p.web-dl`, false},
+		{"name contains", `name contains 1080P`, true},
+		{"name contains missing", `name contains 2160p`, false},
+		{"name starts_with", `name starts_with the.movie`, true},
+		{"name ends_with", `name ends_with web-dl`, true},
+		{"name matches", `name matches "\d{4}\.1080p"`, true},
+		{"name matches missing", `name matches "^movie"`, false},
+		{"file any", `file ends_with .srt`, true},
+		{"file none", `file ends_with .mp4`, false},
+		{"file equals", `file = sample.mkv`, true},
+		{"arr", `arr = Radarr`, true},
+		{"arr not equals", `arr != sonarr`, true},
+		{"debrid", `debrid starts_with real`, true},
+
+		// bad, bare or compared
+		{"bad bare", `bad`, false},
+		{"bad equals false", `bad = false`, true},
+		{"bad not equals true", `bad != true`, true},
+		{"not bad", `NOT bad`, true},
+
+		// numbers
+		{"files equals", `files = 3`, true},
+		{"files greater", `files > 3`, false},
+		{"files greater or equal", `files >= 3`, true},
+		{"files less", `files < 4`, true},
+		{"files less or equal", `files <= 2`, false},
+		{"files not equals", `files != 3`, false},
+		{"size greater", `size > 1GB`, true},
+		{"size less", `size < 500MB`, false},
+		{"size plain bytes", `size >= 4294967296`, true},
+		{"added within hours", `added < 24h`, true},
+		{"added within days", `added < 1d`, true},
+		{"added older", `added > 2h`, true},
+		{"added older than days", `added > 7d`, false},
+
+		// keywords and precedence
+		{"and", `arr = radarr AND name contains 1080p`, true},
+		{"and false", `arr = radarr AND name contains 2160p`, false},
+		{"or", `name contains 2160p OR name contains 1080p`, true},
+		{"or false", `name contains 2160p OR name contains 720p`, false},
+		{"and binds tighter than or", `name contains 2160p AND arr = sonarr OR debrid = realdebrid`, true},
+		{"and binds tighter than or, right", `arr = radarr OR files = 0 AND bad`, true},
+		{"parentheses", `(name contains 2160p OR name contains 1080p) AND arr = radarr`, true},
+		{"parentheses change precedence", `name contains 2160p AND (arr = sonarr OR debrid = realdebrid)`, false},
+		{"nested parentheses", `((arr = radarr) AND (files > 1 OR bad))`, true},
+		{"not", `NOT arr = sonarr`, true},
+		{"not binds tighter than and", `NOT arr = radarr AND bad`, false},
+		{"not group", `NOT (arr = radarr AND bad = false)`, false},
+		{"double not", `NOT NOT arr = radarr`, true},
+		{"lower case keywords", `arr = radarr and not bad or files = 0`, true},
+		{"operator keywords are case-insensitive", `name CONTAINS 1080p`, true},
+
+		// quoting
+		{"double quotes", `name contains ".2020.1080p"`, true},
+		{"single quotes", `name contains '.2020.1080p'`, true},
+		{"quoted keyword is a value", `name contains "AND"`, false},
+		{"quoted value with spaces", `file = "the movie.mkv"`, false},
+		{"escaped quote", `name contains "say \"hi\""`, false},
+		{"no spaces around operators", `files>=3 AND arr=radarr`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseDirectoryFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseDirectoryFilter(%q): %v", tt.expr, err)
+			}
+			if got := f.Match(filterMovie, filterNow); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectoryFilterQuotedSpaces(t *testing.T) {
+	s := filterMovie
+	s.Files = []string{"The Movie (2020).mkv"}
+	for _, expr := range []string{`file = "The Movie (2020).mkv"`, `file contains 'movie (2020)'`} {
+		f, err := ParseDirectoryFilter(expr)
+		if err != nil {
+			t.Fatalf("ParseDirectoryFilter(%q): %v", expr, err)
+		}
+		if !f.Match(s, filterNow) {
+			t.Errorf("Match(%q) = false, want true", expr)
+		}
+	}
+}
+
+func TestDirectoryFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"unknown field", `title contains x`},
+		{"missing operator", `name`},
+		{"missing value", `name contains`},
+		{"string field with number operator", `name > 3`},
+		{"number field with string operator", `files contains 3`},
+		{"size with string operator", `size starts_with 1`},
+		{"added with string operator", `added matches 1d`},
+		{"bad with number operator", `bad > 1`},
+		{"invalid boolean", `bad = maybe`},
+		{"invalid number", `files = many`},
+		{"invalid size", `size > big`},
+		{"invalid duration", `added < soon`},
+		{"invalid regex", `name matches "("`},
+		{"unterminated quote", `name contains "1080p`},
+		{"missing closing parenthesis", `(name contains 1080p`},
+		{"stray closing parenthesis", `name contains 1080p)`},
+		{"empty parentheses", `()`},
+		{"lone bang", `name ! x`},
+		{"dangling and", `name contains 1080p AND`},
+		{"dangling or", `OR name contains 1080p`},
+		{"dangling not", `NOT`},
+		{"quoted field", `"name" contains x`},
+		{"parenthesis as value", `name contains (`},
+		{"trailing value", `name contains 1080p web`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDirectoryFilter(tt.expr); err == nil {
+				t.Errorf("ParseDirectoryFilter(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
+
+func TestDirectoryFilterNil(t *testing.T) {
+	var f *DirectoryFilter
+	if !f.Match(filterMovie, filterNow) {
+		t.Error("a nil filter should match everything")
+	}
+}
+
+func TestWebdavDirectoriesCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  WebdavDirectories
+		want bool
+	}{
+		{"empty", WebdavDirectories{}, true},
+		{"include", WebdavDirectories{Filters: map[string]string{"include": "1080p"}}, true},
+		{"exclude", WebdavDirectories{Filters: map[string]string{"exclude": "1080p"}}, false},
+		{"not_starts_with", WebdavDirectories{Filters: map[string]string{"not_starts_with": "the"}}, false},
+		{"not_exact_match", WebdavDirectories{Filters: map[string]string{"not_exact_match": "other"}}, true},
+		{"size_gt", WebdavDirectories{Filters: map[string]string{"size_gt": "1GB"}}, true},
+		{"last_added", WebdavDirectories{Filters: map[string]string{"last_added": "1h"}}, false},
+		{"filters and expression", WebdavDirectories{
+			Filters: map[string]string{"include": "1080p"},
+			Filter:  "arr = radarr",
+		}, true},
+		{"expression must match too", WebdavDirectories{
+			Filters: map[string]string{"include": "1080p"},
+			Filter:  "arr = sonarr",
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.dir.Compile()
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := f.Match(filterMovie, filterNow); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, dir := range []WebdavDirectories{
+		{Filters: map[string]string{"unknown": "x"}},
+		{Filters: map[string]string{"regex": "("}},
+		{Filter: "name contains"},
+	} {
+		if _, err := dir.Compile(); err == nil {
+			t.Errorf("Compile(%+v) succeeded, want an error", dir)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DebridMapping describes a debrid API declaratively, so it can be used without a dedicated client.
+// URLs and form values may contain the placeholders {id}, {magnet}, {hash}, {hashes} and {link}.
+// Field paths use a JSONPath-like syntax: "data.magnets[0].id", "$" is the value itself.
+type DebridMapping struct {
+	Host string            `json:"host,omitempty"`
+	Auth DebridMappingAuth `json:"auth,omitempty"`
+
+	AddMagnet    *DebridEndpoint `json:"add_magnet,omitempty"`   // Result must point to the created torrent
+	Status       *DebridEndpoint `json:"status,omitempty"`       // Result must point to the torrent
+	List         *DebridEndpoint `json:"list,omitempty"`         // Result must point to a list of torrents
+	Delete       *DebridEndpoint `json:"delete,omitempty"`       // Result is ignored
+	Files        *DebridEndpoint `json:"files,omitempty"`        // Optional, Result must point to a list of files
+	Unrestrict   *DebridEndpoint `json:"unrestrict,omitempty"`   // Optional, Result must point to the download link
+	Availability *DebridEndpoint `json:"availability,omitempty"` // Optional, Result must point to a list of cached hashes
+
+	// Error is the path to an error message, a non-empty value fails the request
+	Error string `json:"error,omitempty"`
+
+	// Field paths, relative to a torrent or a file
+	TorrentFields map[string]string `json:"torrent_fields,omitempty"` // id, name, hash, magnet, status, progress, size, speed, seeders, added, link, files
+	FileFields    map[string]string `json:"file_fields,omitempty"`    // id, name, path, size, link
+
+	// StatusMap maps provider statuses to downloaded, downloading or error
+	StatusMap map[string]string `json:"status_map,omitempty"`
+}
+
+type DebridMappingAuth struct {
+	Header string `json:"header,omitempty"` // e.g. Authorization
+	Prefix string `json:"prefix,omitempty"` // e.g. "Bearer "
+	Query  string `json:"query,omitempty"`  // query parameter holding the api key, e.g. key
+}
+
+type DebridEndpoint struct {
+	Method string            `json:"method,omitempty"` // GET by default
+	URL    string            `json:"url,omitempty"`    // Relative to the host
+	Form   map[string]string `json:"form,omitempty"`   // Sent url-encoded in the body
+	Result string            `json:"result,omitempty"` // Path to the interesting part of the response, the whole response by default
+}
+
+func (m *DebridMapping) Validate() error {
+	if m.Host == "" {
+		return errors.New("mapping host is required")
+	}
+	required := map[string]*DebridEndpoint{
+		"add_magnet": m.AddMagnet,
+		"status":     m.Status,
+		"list":       m.List,
+		"delete":     m.Delete,
+	}
+	for name, e := range required {
+		if e == nil || e.URL == "" {
+			return fmt.Errorf("mapping %s endpoint is required", name)
+		}
+	}
+	if m.TorrentFields["id"] == "" {
+		return errors.New("mapping torrent_fields.id is required")
+	}
+	for _, status := range m.StatusMap {
+		switch status {
+		case "downloaded", "downloading", "error":
+		default:
+			return fmt.Errorf("mapping status_map value %s must be downloaded, downloading or error", status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package config
+
+// MockDebrid configures the in-memory "mock" debrid, used to run decypharr without a real provider
+type MockDebrid struct {
+	CachedHashes          []string `json:"cached_hashes,omitempty"`           // Hashes reported as cached, "*" for all
+	DownloadTime          string   `json:"download_time,omitempty"`           // How long uncached torrents take to download, e.g. 30s
+	Files                 int      `json:"files,omitempty"`                   // Number of files per torrent, 1 by default
+	FileSize              string   `json:"file_size,omitempty"`               // Size of each file, 10MB by default
+	HosterUnavailableRate float64  `json:"hoster_unavailable_rate,omitempty"` // Share of download links failing with hoster unavailable, 0 to 1
+	TrafficExceededRate   float64  `json:"traffic_exceeded_rate,omitempty"`   // Share of download links failing with traffic exceeded, 0 to 1
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// FileSelection holds the rules choosing which files of a torrent are downloaded.
+// Rules set on an arr apply on top of the debrid's.
+type FileSelection struct {
+	Include      []string `json:"include,omitempty"`       // Regexes, files must match at least one
+	Exclude      []string `json:"exclude,omitempty"`       // Regexes, matching files are skipped
+	Languages    []string `json:"languages,omitempty"`     // Files tagged with another language are skipped, e.g. eng, french. Untagged files are kept
+	Largest      int      `json:"largest,omitempty"`       // Only keep the N largest files
+	EpisodesOnly bool     `json:"episodes_only,omitempty"` // Only keep files named like an episode, e.g. S01E02 or 1x02
+	SkipExtras   bool     `json:"skip_extras,omitempty"`   // Skip files in extras folders, e.g. Extras, Featurettes, Behind The Scenes
+}
+
+func (fs *FileSelection) Validate() error {
+	for _, expr := range append(append([]string{}, fs.Include...), fs.Exclude...) {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid file selection regex %s: %w", expr, err)
+		}
+	}
+	if fs.Largest < 0 {
+		return fmt.Errorf("file selection largest must be positive")
+	}
+	return nil
+}
@@ -1,12 +1,14 @@
 package config
 
 type WebdavDirectories struct {
-	Filters map[string]string `json:"filters,omitempty"`
-	//SaveStrms bool              `json:"save_streams,omitempty"`
+	Filters   map[string]string `json:"filters,omitempty"`
+	Filter    string            `json:"filter,omitempty"`     // filter expression, see ParseDirectoryFilter
+	SaveStrms bool              `json:"save_strms,omitempty"` // serve the media files of the folder as .strm files
 }
 
 type WebDav struct {
 	TorrentsRefreshInterval      string `json:"torrents_refresh_interval,omitempty"`
+	TorrentsFullRefreshInterval  string `json:"torrents_full_refresh_interval,omitempty"`
 	DownloadLinksRefreshInterval string `json:"download_links_refresh_interval,omitempty"`
 	Workers                      int    `json:"workers,omitempty"`
 	AutoExpireLinksAfter         string `json:"auto_expire_links_after,omitempty"`
@@ -14,6 +16,36 @@ type WebDav struct {
 
 	// Folder
 	FolderNaming string `json:"folder_naming,omitempty"`
+	MediaLibrary bool   `json:"media_library,omitempty"` // serve movies/ and shows/ folders parsed from the release names
+
+	// Strm
+	StrmURL string `json:"strm_url,omitempty"` // address players reach Decypharr at, written into .strm files
+
+	// Streaming
+	StreamChunkSize     string `json:"stream_chunk_size,omitempty"`      // size of the ranges fetched from the debrid, 0 streams without chunks
+	StreamReadAhead     string `json:"stream_read_ahead,omitempty"`      // data fetched ahead of a sequential reader
+	StreamCacheSize     string `json:"stream_cache_size,omitempty"`      // memory kept for chunks shared across readers
+	StreamDiskCacheSize string `json:"stream_disk_cache_size,omitempty"` // disk kept for chunks, 0 disables it
+
+	// Link prefetch
+	LinkPrefetchRate   int    `json:"link_prefetch_rate,omitempty"`   // links refreshed in the background per minute
+	LinkPrefetchRecent string `json:"link_prefetch_recent,omitempty"` // how long an opened file keeps its link warm, 0 disables
+	LinkPrefetchNew    string `json:"link_prefetch_new,omitempty"`    // torrents added within this window have their links warmed, 0 disables
+
+	// Health scan
+	HealthScanInterval  string `json:"health_scan_interval,omitempty"`   // how often every torrent is checked, 0 disables the scan
+	HealthScanBatchSize int    `json:"health_scan_batch_size,omitempty"` // torrents checked per minute
+
+	// Repair
+	RepairWorkers       int    `json:"repair_workers,omitempty"`        // concurrent reinserts
+	ReinsertMaxAttempts int    `json:"reinsert_max_attempts,omitempty"` // failed reinserts before the final action
+	ReinsertBackoff     string `json:"reinsert_backoff,omitempty"`      // comma separated delays between attempts, the last one repeats
+	ReinsertFallback    bool   `json:"reinsert_fallback,omitempty"`     // try the other debrids once the attempts run out
+	ReinsertFinalAction string `json:"reinsert_final_action,omitempty"` // bad, delete or notify_arr
+
+	// Trash
+	TrashRetention string `json:"trash_retention,omitempty"` // how long deleted torrents are kept
+	TrashMaxSize   string `json:"trash_max_size,omitempty"`  // oldest torrents are purged past this size
 
 	// Rclone
 	RcUrl         string `json:"rc_url,omitempty"`
@@ -0,0 +1,205 @@
+package request
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+var CircuitOpenError = &HTTPError{
+	StatusCode: 503,
+	Message:    "Provider is unavailable, circuit is open",
+	Code:       "circuit_open",
+}
+
+// BreakerStats is a snapshot of a CircuitBreaker, safe to serialize
+type BreakerStats struct {
+	State               BreakerState `json:"state"`
+	Requests            int64        `json:"requests"`
+	Failures            int64        `json:"failures"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	AverageLatency      string       `json:"average_latency"`
+	LastError           string       `json:"last_error,omitempty"`
+	// Pointers so omitempty leaves them out while unset
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"`
+}
+
+// CircuitBreaker tracks the health of a provider's API.
+// It opens after repeated server errors or timeouts, rejects requests while open,
+// then lets a single probe through (half-open) before closing again.
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	threshold   int
+	openFor     time.Duration
+	maxOpenFor  time.Duration
+	currentOpen time.Duration
+
+	state               BreakerState
+	consecutiveFailures int
+	requests            int64
+	failures            int64
+	avgLatency          time.Duration
+	lastError           string
+	lastFailure         time.Time
+	openedAt            time.Time
+	probing             bool
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures.
+// It stays open for openFor, doubling every time a probe fails, up to 10x openFor.
+func NewCircuitBreaker(threshold int, openFor time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   max(threshold, 1),
+		openFor:     openFor,
+		maxOpenFor:  openFor * 10,
+		currentOpen: openFor,
+		state:       BreakerClosed,
+	}
+}
+
+// State returns the current state, moving from open to half-open once the open period is over
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.currentState()
+}
+
+func (cb *CircuitBreaker) currentState() BreakerState {
+	if cb.state == BreakerOpen && time.Since(cb.openedAt) >= cb.currentOpen {
+		cb.state = BreakerHalfOpen
+		cb.probing = false
+	}
+	return cb.state
+}
+
+// IsOpen reports whether requests are currently being rejected
+func (cb *CircuitBreaker) IsOpen() bool {
+	return cb.State() == BreakerOpen
+}
+
+// Allow reports whether a request may go out.
+// In half-open state only one probe request is allowed at a time.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.currentState() {
+	case BreakerOpen:
+		return CircuitOpenError
+	case BreakerHalfOpen:
+		if cb.probing {
+			return CircuitOpenError
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// Record registers the outcome of a request
+func (cb *CircuitBreaker) Record(resp *http.Response, err error, latency time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.requests++
+	if cb.avgLatency == 0 {
+		cb.avgLatency = latency
+	} else {
+		// Exponentially weighted, recent requests matter more
+		cb.avgLatency = (cb.avgLatency*4 + latency) / 5
+	}
+
+	if !isBreakerFailure(resp, err) {
+		cb.consecutiveFailures = 0
+		if cb.state != BreakerClosed {
+			cb.state = BreakerClosed
+			cb.currentOpen = cb.openFor
+		}
+		cb.probing = false
+		return
+	}
+
+	cb.failures++
+	cb.consecutiveFailures++
+	cb.lastFailure = time.Now()
+	if err != nil {
+		cb.lastError = err.Error()
+	} else {
+		cb.lastError = resp.Status
+	}
+
+	switch cb.state {
+	case BreakerHalfOpen:
+		// The probe failed, back off for longer
+		cb.currentOpen = min(cb.currentOpen*2, cb.maxOpenFor)
+		cb.trip()
+	case BreakerClosed:
+		if cb.consecutiveFailures >= cb.threshold {
+			cb.trip()
+		}
+	}
+}
+
+func (cb *CircuitBreaker) trip() {
+	cb.state = BreakerOpen
+	cb.openedAt = time.Now()
+	cb.probing = false
+}
+
+// Reset closes the breaker and clears its counters
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = BreakerClosed
+	cb.consecutiveFailures = 0
+	cb.currentOpen = cb.openFor
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) Stats() BreakerStats {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	stats := BreakerStats{
+		State:               cb.currentState(),
+		Requests:            cb.requests,
+		Failures:            cb.failures,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		AverageLatency:      cb.avgLatency.Round(time.Millisecond).String(),
+		LastError:           cb.lastError,
+	}
+	if !cb.lastFailure.IsZero() {
+		lastFailure := cb.lastFailure
+		stats.LastFailure = &lastFailure
+	}
+	if cb.state != BreakerClosed {
+		openedAt := cb.openedAt
+		retryAt := cb.openedAt.Add(cb.currentOpen)
+		stats.OpenedAt = &openedAt
+		stats.RetryAt = &retryAt
+	}
+	return stats
+}
+
+// isBreakerFailure reports whether the outcome says something about the provider's health.
+// Only server errors and timeouts count, client errors are the caller's problem.
+func isBreakerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return isRetryableError(err)
+	}
+	return resp != nil && resp.StatusCode >= 500
+}
@@ -0,0 +1,31 @@
+package request
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreakerStatsJSON(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+	stats, err := json.Marshal(cb.Stats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"last_failure", "opened_at", "retry_at"} {
+		if strings.Contains(string(stats), field) {
+			t.Errorf("stats of a healthy breaker = %s, want no %s", stats, field)
+		}
+	}
+
+	cb.Record(&http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}, nil, time.Millisecond)
+	s := cb.Stats()
+	if s.State != BreakerOpen || s.LastFailure == nil || s.OpenedAt == nil || s.RetryAt == nil {
+		t.Fatalf("Stats = %+v, want an open breaker with its timestamps", s)
+	}
+	if got := s.RetryAt.Sub(*s.OpenedAt); got != time.Minute {
+		t.Errorf("retry in %v, want %v", got, time.Minute)
+	}
+}
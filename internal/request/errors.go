@@ -1,5 +1,12 @@
 package request
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
 type HTTPError struct {
 	StatusCode int
 	Message    string
@@ -10,12 +17,52 @@ func (e *HTTPError) Error() string {
 	return e.Message
 }
 
+// Provider failures. Providers map their own error codes to these, wrapping them
+// with fmt.Errorf("%w") to keep the provider's message. Callers branch with errors.Is.
+
+var AuthInvalidError = &HTTPError{
+	StatusCode: 401,
+	Message:    "API key is invalid or expired",
+	Code:       "auth_invalid",
+}
+
+var RateLimitedError = &HTTPError{
+	StatusCode: 429,
+	Message:    "Rate limited",
+	Code:       "rate_limited",
+}
+
+var TorrentNotFoundError = &HTTPError{
+	StatusCode: 404,
+	Message:    "Torrent not found",
+	Code:       "torrent_not_found",
+}
+
+var InfringingFileError = &HTTPError{
+	StatusCode: 451,
+	Message:    "File was removed for infringement",
+	Code:       "infringing_file",
+}
+
+var TooManyActiveDownloadsError = &HTTPError{
+	StatusCode: 429,
+	Message:    "Too many active downloads",
+	Code:       "too_many_active_downloads",
+}
+
+// HosterUnavailableError means the file is gone from its hoster. Outages are ServiceUnavailableError.
 var HosterUnavailableError = &HTTPError{
 	StatusCode: 503,
 	Message:    "Hoster is unavailable",
 	Code:       "hoster_unavailable",
 }
 
+var ServiceUnavailableError = &HTTPError{
+	StatusCode: 503,
+	Message:    "Service is temporarily unavailable",
+	Code:       "service_unavailable",
+}
+
 var TrafficExceededError = &HTTPError{
 	StatusCode: 503,
 	Message:    "Traffic exceeded",
@@ -28,8 +75,61 @@ var ErrLinkBroken = &HTTPError{
 	Code:       "file_unavailable",
 }
 
-var TorrentNotFoundError = &HTTPError{
-	StatusCode: 404,
-	Message:    "Torrent not found",
-	Code:       "torrent_not_found",
+var UnknownError = &HTTPError{
+	StatusCode: 500,
+	Message:    "Unknown provider error",
+	Code:       "unknown",
+}
+
+// ProviderError wraps kind with the provider's own message
+func ProviderError(kind *HTTPError, format string, args ...any) error {
+	return fmt.Errorf("%w: %s", kind, fmt.Sprintf(format, args...))
+}
+
+// StatusError maps an HTTP status returned by a provider API to an error kind
+func StatusError(statusCode int) *HTTPError {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return AuthInvalidError
+	case http.StatusTooManyRequests:
+		return RateLimitedError
+	case http.StatusUnavailableForLegalReasons:
+		return InfringingFileError
+	}
+	if statusCode >= 500 {
+		return ServiceUnavailableError
+	}
+	return UnknownError
+}
+
+// DownloadError maps a failed response from a download host to an error kind
+func DownloadError(statusCode int, body []byte) *HTTPError {
+	switch statusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return ErrLinkBroken
+	case http.StatusServiceUnavailable:
+		if strings.Contains(strings.ToLower(string(body)), "exceeded your traffic") {
+			return TrafficExceededError
+		}
+		return ServiceUnavailableError
+	default:
+		return StatusError(statusCode)
+	}
+}
+
+// ErrorCode returns the code of the error kind wrapped in err, or "unknown"
+func ErrorCode(err error) string {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return UnknownError.Code
+}
+
+// IsTemporary reports whether retrying later may succeed
+func IsTemporary(err error) bool {
+	return errors.Is(err, RateLimitedError) ||
+		errors.Is(err, TooManyActiveDownloadsError) ||
+		errors.Is(err, ServiceUnavailableError) ||
+		errors.Is(err, CircuitOpenError)
 }
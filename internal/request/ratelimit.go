@@ -0,0 +1,163 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// fallbackRate is used once an unlimited provider starts answering 429
+	fallbackRate = rate.Limit(5)
+	// growAfter is the number of consecutive successes before the rate grows again
+	growAfter = 20
+	// growFactor and shrinkFactor adjust the rate on success and on 429
+	growFactor   = 1.1
+	shrinkFactor = 0.5
+	// maxPause caps how long a provider header can pause requests
+	maxPause = 10 * time.Minute
+)
+
+// RateLimiter is a token bucket that adapts to the provider. It halves its rate on 429 and grows it back
+// to the configured rate after a run of successes. Retry-After and X-RateLimit-* headers pause every request
+// to the host until the window resets. A provider's clients share a single RateLimiter.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limiter   *rate.Limiter
+	base      rate.Limit
+	min       rate.Limit
+	successes int
+	pauses    map[string]time.Time
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per second. rate.Inf only honours the headers
+// until the provider starts answering 429.
+func NewRateLimiter(limit rate.Limit, burst int) *RateLimiter {
+	minLimit := limit / 16
+	if limit == rate.Inf {
+		minLimit = fallbackRate / 16
+	}
+	return &RateLimiter{
+		limiter: rate.NewLimiter(limit, max(burst, 1)),
+		base:    limit,
+		min:     minLimit,
+		pauses:  make(map[string]time.Time),
+	}
+}
+
+// Wait blocks until the host is no longer paused and a token is available
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if wait := l.pausedFor(host); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+func (l *RateLimiter) pausedFor(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := l.pauses[host]
+	if !ok {
+		return 0
+	}
+	wait := time.Until(until)
+	if wait <= 0 {
+		delete(l.pauses, host)
+		return 0
+	}
+	return wait
+}
+
+// Paused reports whether requests to host are paused by a provider header
+func (l *RateLimiter) Paused(host string) bool {
+	return l.pausedFor(host) > 0
+}
+
+// Limit returns the current rate, in requests per second
+func (l *RateLimiter) Limit() rate.Limit {
+	return l.limiter.Limit()
+}
+
+// Observe adjusts the limiter to a provider response
+func (l *RateLimiter) Observe(resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	host := resp.Request.URL.Host
+	if until, ok := resetTime(resp); ok {
+		l.pause(host, until)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := l.limiter.Limit()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		l.successes = 0
+		next := current * shrinkFactor
+		if current == rate.Inf {
+			next = fallbackRate
+		}
+		l.limiter.SetLimit(max(next, l.min))
+		return
+	}
+	if current == l.base {
+		return
+	}
+	l.successes++
+	if l.successes < growAfter {
+		return
+	}
+	l.successes = 0
+	next := current * growFactor
+	if next >= l.base || (l.base == rate.Inf && next >= fallbackRate*10) {
+		next = l.base
+	}
+	l.limiter.SetLimit(next)
+}
+
+func (l *RateLimiter) pause(host string, until time.Time) {
+	if limit := time.Now().Add(maxPause); until.After(limit) {
+		until = limit
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pauses[host]) {
+		l.pauses[host] = until
+	}
+}
+
+// resetTime reads when the provider accepts requests again from Retry-After, or from X-RateLimit-Reset
+// once X-RateLimit-Remaining is exhausted
+func resetTime(resp *http.Response) (time.Time, bool) {
+	now := time.Now()
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return now.Add(time.Duration(secs) * time.Second), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t, true
+		}
+	}
+	if n, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err != nil || n > 0 {
+		return time.Time{}, false
+	}
+	reset, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset"), 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// Providers send either a unix timestamp or a number of seconds
+	if reset > 1e9 {
+		return time.Unix(int64(reset), 0), true
+	}
+	return now.Add(time.Duration(reset * float64(time.Second))), true
+}
@@ -53,7 +53,7 @@ type ClientOption func(*Client)
 // Client represents an HTTP client with additional capabilities
 type Client struct {
 	client          *http.Client
-	rateLimiter     *rate.Limiter
+	rateLimiter     *RateLimiter
 	headers         map[string]string
 	headersMu       sync.RWMutex
 	maxRetries      int
@@ -62,6 +62,7 @@ type Client struct {
 	retryableStatus map[int]struct{}
 	logger          zerolog.Logger
 	proxy           string
+	breaker         *CircuitBreaker
 }
 
 // WithMaxRetries sets the maximum number of retry attempts
@@ -84,8 +85,8 @@ func WithRedirectPolicy(policy func(req *http.Request, via []*http.Request) erro
 	}
 }
 
-// WithRateLimiter sets a rate limiter
-func WithRateLimiter(rl *rate.Limiter) ClientOption {
+// WithRateLimiter sets a rate limiter. Clients talking to the same provider should share it.
+func WithRateLimiter(rl *RateLimiter) ClientOption {
 	return func(c *Client) {
 		c.rateLimiter = rl
 	}
@@ -128,6 +129,13 @@ func WithRetryableStatus(statusCodes ...int) ClientOption {
 	}
 }
 
+// WithCircuitBreaker makes the client report to, and respect, a circuit breaker
+func WithCircuitBreaker(cb *CircuitBreaker) ClientOption {
+	return func(c *Client) {
+		c.breaker = cb
+	}
+}
+
 func WithProxy(proxyURL string) ClientOption {
 	return func(c *Client) {
 		c.proxy = proxyURL
@@ -137,13 +145,27 @@ func WithProxy(proxyURL string) ClientOption {
 // doRequest performs a single HTTP request with rate limiting
 func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
 	if c.rateLimiter != nil {
-		err := c.rateLimiter.Wait(req.Context())
+		err := c.rateLimiter.Wait(req.Context(), req.URL.Host)
 		if err != nil {
 			return nil, fmt.Errorf("rate limiter wait: %w", err)
 		}
 	}
 
-	return c.client.Do(req)
+	if c.breaker != nil {
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if c.breaker != nil {
+		c.breaker.Record(resp, err, time.Since(start))
+	}
+	if c.rateLimiter != nil && err == nil {
+		c.rateLimiter.Observe(resp)
+	}
+	return resp, err
 }
 
 // Do performs an HTTP request with retries for certain status codes
@@ -208,6 +230,11 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		// Close the response body before retrying
 		resp.Body.Close()
 
+		// The rate limiter holds the next attempt until the window the provider asked for resets
+		if resp.StatusCode == http.StatusTooManyRequests && c.rateLimiter != nil && c.rateLimiter.Paused(req.URL.Host) {
+			continue
+		}
+
 		// Apply backoff with jitter
 		jitter := time.Duration(rand.Int63n(int64(backoff / 4)))
 		sleepTime := backoff + jitter
@@ -245,7 +272,7 @@ func (c *Client) MakeRequest(req *http.Request) ([]byte, error) {
 	}
 
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP error %d: %s", res.StatusCode, string(bodyBytes))
+		return nil, ProviderError(StatusError(res.StatusCode), "HTTP error %d: %s", res.StatusCode, string(bodyBytes))
 	}
 
 	return bodyBytes, nil
@@ -340,16 +367,18 @@ func New(options ...ClientOption) *Client {
 	return client
 }
 
-func ParseRateLimit(rateStr string) *rate.Limiter {
+// ParseRateLimit builds an adaptive limiter from a "200/minute" string.
+// Without a valid rate, requests are only held back by the provider's rate limit headers.
+func ParseRateLimit(rateStr string) *RateLimiter {
 	parts := strings.SplitN(rateStr, "/", 2)
 	if len(parts) != 2 {
-		return nil
+		return NewRateLimiter(rate.Inf, 1)
 	}
 
 	// parse count
 	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
 	if err != nil || count <= 0 {
-		return nil
+		return NewRateLimiter(rate.Inf, 1)
 	}
 
 	// normalize unit
@@ -364,13 +393,13 @@ func ParseRateLimit(rateStr string) *rate.Limiter {
 	}
 	switch unit {
 	case "minute", "min":
-		return rate.NewLimiter(rate.Limit(float64(count)/60.0), burstSize)
+		return NewRateLimiter(rate.Limit(float64(count)/60.0), burstSize)
 	case "second", "sec":
-		return rate.NewLimiter(rate.Limit(float64(count)), burstSize)
+		return NewRateLimiter(rate.Limit(float64(count)), burstSize)
 	case "hour", "hr":
-		return rate.NewLimiter(rate.Limit(float64(count)/3600.0), burstSize)
+		return NewRateLimiter(rate.Limit(float64(count)/3600.0), burstSize)
 	default:
-		return nil
+		return NewRateLimiter(rate.Inf, 1)
 	}
 }
 
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Release is what can be told about a media release from its name
+type Release struct {
+	Title      string
+	Year       int
+	Season     int // 0 when the release isn't a show
+	Episode    int // 0 for a season pack
+	Resolution string
+}
+
+var (
+	releaseEpisodeRegex    = regexp.MustCompile(`(?i)\bs(\d{1,2})[ .-]?e(\d{1,3})`)
+	releaseCrossRegex      = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`)
+	releaseSeasonRegex     = regexp.MustCompile(`(?i)\b(?:s|season[ .-]?)(\d{1,2})\b`)
+	releaseYearRegex       = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+	releaseResolutionRegex = regexp.MustCompile(`(?i)\b(480p|576p|720p|1080[pi]|2160p|4k|uhd)\b`)
+	releaseTagRegex        = regexp.MustCompile(`(?i)\b(complete|bluray|blu-ray|bdrip|brrip|web-?dl|webrip|web|hdtv|dvdrip|remux|x264|x265|h264|h265|hevc|proper|repack|extended|unrated|multi)\b`)
+	releaseGroupRegex      = regexp.MustCompile(`^\s*\[[^]]*]\s*`)
+	releaseSeparators      = strings.NewReplacer(".", " ", "_", " ")
+	// releaseExtras are the extensions stripped besides the media ones
+	releaseExtras = map[string]bool{".srt": true, ".sub": true, ".idx": true, ".ass": true, ".vtt": true, ".nfo": true}
+)
+
+// ParseRelease parses a release or file name, e.g. "The.Show.S01E02.1080p.WEB-DL" or "Movie (2019) [2160p]"
+func ParseRelease(name string) Release {
+	name = path.Base(name)
+	if ext := strings.ToLower(path.Ext(name)); IsMediaFile(name) || releaseExtras[ext] {
+		name = name[:len(name)-len(ext)]
+	}
+	name = releaseGroupRegex.ReplaceAllString(name, "")
+	name = releaseSeparators.Replace(name)
+
+	var r Release
+	// the title runs up to the first marker
+	titleEnd := len(name)
+	mark := func(loc []int) {
+		if loc != nil && loc[0] < titleEnd {
+			titleEnd = loc[0]
+		}
+	}
+
+	if m := releaseEpisodeRegex.FindStringSubmatchIndex(name); m != nil {
+		r.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		r.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+		mark(m)
+	} else if m := releaseCrossRegex.FindStringSubmatchIndex(name); m != nil {
+		r.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		r.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+		mark(m)
+	} else if m := releaseSeasonRegex.FindStringSubmatchIndex(name); m != nil {
+		r.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		mark(m)
+	}
+	if m := releaseResolutionRegex.FindStringIndex(name); m != nil {
+		r.Resolution = strings.ToLower(name[m[0]:m[1]])
+		switch r.Resolution {
+		case "4k", "uhd":
+			r.Resolution = "2160p"
+		case "1080i":
+			r.Resolution = "1080p"
+		}
+		mark(m)
+	}
+	mark(releaseTagRegex.FindStringIndex(name))
+
+	// the year is the last one in the title, one at the start belongs to it, e.g. "2001 A Space Odyssey 1968"
+	var year []int
+	for _, m := range releaseYearRegex.FindAllStringSubmatchIndex(name[:titleEnd], -1) {
+		if strings.TrimSpace(name[:m[0]]) != "" {
+			year = m
+		}
+	}
+	if year != nil {
+		r.Year, _ = strconv.Atoi(name[year[2]:year[3]])
+		titleEnd = year[0]
+	}
+
+	title := strings.Trim(name[:titleEnd], " -([{")
+	r.Title = strings.Join(strings.Fields(title), " ")
+	return r
+}
+
+// IsShow reports whether the release is a season or an episode of a show
+func (r Release) IsShow() bool {
+	return r.Season > 0 || r.Episode > 0
+}
@@ -0,0 +1,83 @@
+package utils
+
+import "testing"
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		want Release
+	}{
+		// episodes
+		{"The.Show.S01E02.1080p.WEB-DL.x264-GRP.mkv", Release{Title: "The Show", Season: 1, Episode: 2, Resolution: "1080p"}},
+		{"the.show.s1e5.mkv", Release{Title: "the show", Season: 1, Episode: 5}},
+		{"The Show S02 E10 720p", Release{Title: "The Show", Season: 2, Episode: 10, Resolution: "720p"}},
+		{"The.Show.S05E100.mkv", Release{Title: "The Show", Season: 5, Episode: 100}},
+		{"The Show 3x07 720p.mkv", Release{Title: "The Show", Season: 3, Episode: 7, Resolution: "720p"}},
+		{"[HorribleSubs] Some Anime - 1x05 [720p].mkv", Release{Title: "Some Anime", Season: 1, Episode: 5, Resolution: "720p"}},
+		{"Show.2019.S01E01.mkv", Release{Title: "Show", Year: 2019, Season: 1, Episode: 1}},
+		{"shows/The Show/The.Show.S01E02.mkv", Release{Title: "The Show", Season: 1, Episode: 2}},
+		{"The.Show.S01E02.en.srt", Release{Title: "The Show", Season: 1, Episode: 2}},
+
+		// multi-episode files are filed under their first episode
+		{"The.Show.S01E01E02.720p.HDTV.mkv", Release{Title: "The Show", Season: 1, Episode: 1, Resolution: "720p"}},
+		{"The.Show.S01E01-E02.720p.mkv", Release{Title: "The Show", Season: 1, Episode: 1, Resolution: "720p"}},
+		{"The Show - S02E10-E12 - Title.mkv", Release{Title: "The Show", Season: 2, Episode: 10}},
+
+		// season packs
+		{"The.Show.S03.1080p.BluRay", Release{Title: "The Show", Season: 3, Resolution: "1080p"}},
+		{"The.Show.Season.3.Complete", Release{Title: "The Show", Season: 3}},
+		{"Breaking Bad Season 2 Complete 720p", Release{Title: "Breaking Bad", Season: 2, Resolution: "720p"}},
+		{"The_Show_Season-04_720p", Release{Title: "The Show", Season: 4, Resolution: "720p"}},
+
+		// movies and years
+		{"Dr.No.1962.1080p.BluRay", Release{Title: "Dr No", Year: 1962, Resolution: "1080p"}},
+		{"Movie (2019) [2160p].mkv", Release{Title: "Movie", Year: 2019, Resolution: "2160p"}},
+		{"Movie_Name_2010_720p.mp4", Release{Title: "Movie Name", Year: 2010, Resolution: "720p"}},
+		{"Some.Movie.2015.Extended.mkv", Release{Title: "Some Movie", Year: 2015}},
+		{"2001.A.Space.Odyssey.1968.2160p", Release{Title: "2001 A Space Odyssey", Year: 1968, Resolution: "2160p"}},
+		{"Blade.Runner.2049.2017.1080p.mkv", Release{Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p"}},
+		{"1917.2019.1080p.mkv", Release{Title: "1917", Year: 2019, Resolution: "1080p"}},
+		{"2012.2009.720p.mkv", Release{Title: "2012", Year: 2009, Resolution: "720p"}},
+		{"Movie.1999.srt", Release{Title: "Movie", Year: 1999}},
+
+		// resolutions
+		{"Movie.2020.4K.HDR.mkv", Release{Title: "Movie", Year: 2020, Resolution: "2160p"}},
+		{"Movie.2020.UHD.mkv", Release{Title: "Movie", Year: 2020, Resolution: "2160p"}},
+		{"Movie.1080i.mkv", Release{Title: "Movie", Resolution: "1080p"}},
+		{"Movie.576p.mkv", Release{Title: "Movie", Resolution: "576p"}},
+
+		// names that aren't shows
+		{"01 - Pilot.mkv", Release{Title: "01 - Pilot"}},
+		{"Mixtape.1x3.mkv", Release{Title: "Mixtape 1x3"}},
+		{"Sessions.mkv", Release{Title: "Sessions"}},
+		{"Episode.Six.mkv", Release{Title: "Episode Six"}},
+		{"S.W.A.T.mkv", Release{Title: "S W A T"}},
+		{"Apollo.13.1995.mkv", Release{Title: "Apollo 13", Year: 1995}},
+		{"Movie.x264.mkv", Release{Title: "Movie"}},
+		{"readme.txt", Release{Title: "readme txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRelease(tt.name); got != tt.want {
+				t.Errorf("ParseRelease(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseIsShow(t *testing.T) {
+	tests := []struct {
+		release Release
+		want    bool
+	}{
+		{Release{Title: "Movie", Year: 2020}, false},
+		{Release{Title: "Show", Season: 1}, true},
+		{Release{Title: "Show", Season: 1, Episode: 2}, true},
+		{Release{Title: "Show", Episode: 2}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.release.IsShow(); got != tt.want {
+			t.Errorf("%+v.IsShow() = %v, want %v", tt.release, got, tt.want)
+		}
+	}
+}
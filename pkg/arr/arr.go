@@ -28,17 +28,19 @@ const (
 )
 
 type Arr struct {
-	Name             string `json:"name"`
-	Host             string `json:"host"`
-	Token            string `json:"token"`
-	Type             Type   `json:"type"`
-	Cleanup          bool   `json:"cleanup"`
-	SkipRepair       bool   `json:"skip_repair"`
-	DownloadUncached *bool  `json:"download_uncached"`
+	Name             string                `json:"name"`
+	Host             string                `json:"host"`
+	Token            string                `json:"token"`
+	Type             Type                  `json:"type"`
+	Cleanup          bool                  `json:"cleanup"`
+	SkipRepair       bool                  `json:"skip_repair"`
+	DownloadUncached *bool                 `json:"download_uncached"`
+	CheckCached      *bool                 `json:"check_cached"`
+	FileSelection    *config.FileSelection `json:"file_selection"`
 	client           *request.Client
 }
 
-func New(name, host, token string, cleanup, skipRepair bool, downloadUncached *bool) *Arr {
+func New(name, host, token string, cleanup, skipRepair bool, downloadUncached, checkCached *bool) *Arr {
 	return &Arr{
 		Name:             name,
 		Host:             host,
@@ -47,6 +49,7 @@ func New(name, host, token string, cleanup, skipRepair bool, downloadUncached *b
 		Cleanup:          cleanup,
 		SkipRepair:       skipRepair,
 		DownloadUncached: downloadUncached,
+		CheckCached:      checkCached,
 		client:           request.New(),
 	}
 }
@@ -146,7 +149,8 @@ func NewStorage() *Storage {
 	arrs := make(map[string]*Arr)
 	for _, a := range config.Get().Arrs {
 		name := a.Name
-		arrs[name] = New(name, a.Host, a.Token, a.Cleanup, a.SkipRepair, a.DownloadUncached)
+		arrs[name] = New(name, a.Host, a.Token, a.Cleanup, a.SkipRepair, a.DownloadUncached, a.CheckCached)
+		arrs[name].FileSelection = a.FileSelection
 	}
 	return &Storage{
 		Arrs:   arrs,
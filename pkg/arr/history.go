@@ -2,6 +2,7 @@ package arr
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	gourl "net/url"
@@ -74,6 +75,25 @@ func (a *Arr) GetHistory(downloadId, eventType string) *HistorySchema {
 
 }
 
+// MarkAsFailed marks the grabs of a download as failed, so the arr blocklists the release and searches for another
+func (a *Arr) MarkAsFailed(downloadId string) error {
+	history := a.GetHistory(strings.ToUpper(downloadId), "1") // 1 is grabbed
+	if history == nil || len(history.Records) == 0 {
+		return fmt.Errorf("no grab found for %s in %s", downloadId, a.Name)
+	}
+	for _, record := range history.Records {
+		resp, err := a.Request(http.MethodPost, "api/v3/history/failed/"+strconv.Itoa(record.ID), nil)
+		if err != nil {
+			return fmt.Errorf("failed to mark %s as failed: %w", downloadId, err)
+		}
+		resp.Body.Close()
+		if statusOk := strconv.Itoa(resp.StatusCode)[0] == '2'; !statusOk {
+			return fmt.Errorf("failed to mark %s as failed. Status Code: %s", downloadId, resp.Status)
+		}
+	}
+	return nil
+}
+
 func (a *Arr) GetQueue() []QueueSchema {
 	query := gourl.Values{}
 	query.Add("page", "1")
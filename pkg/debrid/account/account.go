@@ -0,0 +1,350 @@
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+// saveInterval throttles saves caused by usage updates. State changes are saved right away.
+const saveInterval = 30 * time.Second
+
+var (
+	ErrNoActiveAccount = errors.New("no active download keys")
+	ErrAccountNotFound = errors.New("account not found")
+)
+
+// Provider is implemented by debrid clients whose download keys are managed by a Manager
+type Provider interface {
+	Accounts() *Manager
+}
+
+// Account is a download key with its usage since the last daily reset
+type Account struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Token          string    `json:"-"`
+	Disabled       bool      `json:"disabled"`
+	DisabledAt     time.Time `json:"disabled_at,omitempty"`
+	DisabledReason string    `json:"disabled_reason,omitempty"`
+	ResetAt        time.Time `json:"reset_at,omitempty"`
+	BytesUsed      int64     `json:"bytes_used"`
+	TotalBytes     int64     `json:"total_bytes"`
+	LastUsed       time.Time `json:"last_used,omitempty"`
+
+	index int
+}
+
+// state is what gets persisted for an account, keyed by the fingerprint of its token
+type state struct {
+	Disabled       bool      `json:"disabled"`
+	DisabledAt     time.Time `json:"disabled_at,omitempty"`
+	DisabledReason string    `json:"disabled_reason,omitempty"`
+	ResetAt        time.Time `json:"reset_at,omitempty"`
+	BytesUsed      int64     `json:"bytes_used"`
+	TotalBytes     int64     `json:"total_bytes"`
+	LastUsed       time.Time `json:"last_used,omitempty"`
+}
+
+type file struct {
+	UsageResetAt time.Time        `json:"usage_reset_at"`
+	Accounts     map[string]state `json:"accounts"`
+}
+
+// Manager keeps track of the download keys of a debrid. Its state survives restarts.
+type Manager struct {
+	mu       sync.Mutex
+	accounts []*Account
+	// usageResetAt is when BytesUsed is next zeroed
+	usageResetAt time.Time
+	filename     string
+	lastSave     time.Time
+	logger       zerolog.Logger
+}
+
+// NewManager creates the manager for the tokens of a debrid, restoring the state saved under the config path
+func NewManager(debrid string, tokens []string) *Manager {
+	cfg := config.Get()
+	m := &Manager{
+		filename:     filepath.Join(cfg.Path, "accounts", debrid+".json"),
+		logger:       logger.New(debrid),
+		usageResetAt: nextReset(time.Now()),
+	}
+	for idx, token := range tokens {
+		m.accounts = append(m.accounts, &Account{
+			ID:    strconv.Itoa(idx),
+			Name:  mask(token),
+			Token: token,
+			index: idx,
+		})
+	}
+	if err := m.load(); err != nil {
+		m.logger.Error().Err(err).Msg("Failed to load accounts")
+	}
+	m.mu.Lock()
+	m.rollover(time.Now())
+	m.mu.Unlock()
+	return m
+}
+
+// nextReset returns the next midnight in CET, when debrids reset their traffic
+func nextReset(now time.Time) time.Time {
+	cet, err := time.LoadLocation("CET")
+	if err != nil {
+		cet = time.UTC
+	}
+	now = now.In(cet)
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, cet)
+}
+
+func fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func mask(token string) string {
+	if len(token) <= 8 {
+		return "****"
+	}
+	return token[:4] + "..." + token[len(token)-4:]
+}
+
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !f.UsageResetAt.IsZero() {
+		m.usageResetAt = f.UsageResetAt
+	}
+	for _, a := range m.accounts {
+		s, ok := f.Accounts[fingerprint(a.Token)]
+		if !ok {
+			continue
+		}
+		a.Disabled = s.Disabled
+		a.DisabledAt = s.DisabledAt
+		a.DisabledReason = s.DisabledReason
+		a.ResetAt = s.ResetAt
+		a.BytesUsed = s.BytesUsed
+		a.TotalBytes = s.TotalBytes
+		a.LastUsed = s.LastUsed
+	}
+	return nil
+}
+
+// save writes the state to disk. Must be called with the lock held.
+func (m *Manager) save() {
+	f := file{
+		UsageResetAt: m.usageResetAt,
+		Accounts:     make(map[string]state, len(m.accounts)),
+	}
+	for _, a := range m.accounts {
+		f.Accounts[fingerprint(a.Token)] = state{
+			Disabled:       a.Disabled,
+			DisabledAt:     a.DisabledAt,
+			DisabledReason: a.DisabledReason,
+			ResetAt:        a.ResetAt,
+			BytesUsed:      a.BytesUsed,
+			TotalBytes:     a.TotalBytes,
+			LastUsed:       a.LastUsed,
+		}
+	}
+	m.lastSave = time.Now()
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		m.logger.Error().Err(err).Msg("Failed to marshal accounts")
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.filename), 0755); err != nil {
+		m.logger.Error().Err(err).Msg("Failed to create accounts directory")
+		return
+	}
+	if err := os.WriteFile(m.filename, data, 0644); err != nil {
+		m.logger.Error().Err(err).Msg("Failed to save accounts")
+	}
+}
+
+// rollover re-enables the accounts whose reset time has passed and zeroes the daily usage
+// once the reset time is reached. Must be called with the lock held.
+func (m *Manager) rollover(now time.Time) {
+	changed := false
+	for _, a := range m.accounts {
+		if a.Disabled && !a.ResetAt.IsZero() && !now.Before(a.ResetAt) {
+			m.enable(a)
+			changed = true
+		}
+	}
+	if !now.Before(m.usageResetAt) {
+		for _, a := range m.accounts {
+			a.BytesUsed = 0
+		}
+		m.usageResetAt = nextReset(now)
+		changed = true
+	}
+	if changed {
+		m.save()
+	}
+}
+
+func (m *Manager) enable(a *Account) {
+	a.Disabled = false
+	a.DisabledAt = time.Time{}
+	a.DisabledReason = ""
+	a.ResetAt = time.Time{}
+}
+
+func (m *Manager) get(id string) *Account {
+	for _, a := range m.accounts {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// Pick returns the active account with the least usage since the last reset
+func (m *Manager) Pick() (Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover(time.Now())
+	var picked *Account
+	for _, a := range m.accounts {
+		if a.Disabled {
+			continue
+		}
+		if picked == nil || a.BytesUsed < picked.BytesUsed {
+			picked = a
+		}
+	}
+	if picked == nil {
+		return Account{}, ErrNoActiveAccount
+	}
+	return *picked, nil
+}
+
+// Disable takes an account out of rotation until the next reset. The only account is never disabled.
+// Returns false if the account is not taken out.
+func (m *Manager) Disable(id, reason string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.accounts) == 1 {
+		m.logger.Info().Msgf("Cannot disable last account: %s", id)
+		return false
+	}
+	a := m.get(id)
+	if a == nil || a.Disabled {
+		return false
+	}
+	now := time.Now()
+	a.Disabled = true
+	a.DisabledAt = now
+	a.DisabledReason = reason
+	a.ResetAt = nextReset(now)
+	m.logger.Info().Msgf("Disabled account %s (%s) until %s: %s", a.ID, a.Name, a.ResetAt.Format(time.RFC3339), reason)
+	m.save()
+	return true
+}
+
+// Enable puts an account back in rotation
+func (m *Manager) Enable(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.get(id)
+	if a == nil {
+		return ErrAccountNotFound
+	}
+	m.enable(a)
+	m.logger.Info().Msgf("Enabled account %s (%s)", a.ID, a.Name)
+	m.save()
+	return nil
+}
+
+// RecordUsage adds bytes downloaded with an account
+func (m *Manager) RecordUsage(id string, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.get(id)
+	if a == nil {
+		return
+	}
+	a.BytesUsed += bytes
+	a.TotalBytes += bytes
+	a.LastUsed = time.Now()
+	if time.Since(m.lastSave) >= saveInterval {
+		m.save()
+	}
+}
+
+// Reset re-enables every account disabled until a reset and zeroes the daily usage
+func (m *Manager) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, a := range m.accounts {
+		if a.Disabled && !a.ResetAt.IsZero() {
+			m.enable(a)
+		}
+		a.BytesUsed = 0
+	}
+	m.usageResetAt = nextReset(now)
+	m.save()
+}
+
+// List returns a copy of the accounts, ordered by ID
+func (m *Manager) List() []Account {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover(time.Now())
+	accounts := make([]Account, 0, len(m.accounts))
+	for _, a := range m.accounts {
+		accounts = append(accounts, *a)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].index < accounts[j].index
+	})
+	return accounts
+}
+
+// Rotate calls fn with the least used account. When fn fails with request.TrafficExceededError the
+// account is disabled and fn is retried with the next one, until no account is left.
+func Rotate[T any](m *Manager, reason string, fn func(Account) (T, error)) (T, error) {
+	for {
+		acc, err := m.Pick()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		result, err := fn(acc)
+		if err == nil || !errors.Is(err, request.TrafficExceededError) {
+			return result, err
+		}
+		if !m.Disable(acc.ID, reason) {
+			return result, err
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/sirrobot01/decypharr/internal/logger"
 	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/account"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"net/http"
 	gourl "net/url"
@@ -21,9 +22,10 @@ type AllDebrid struct {
 	Name             string
 	Host             string `json:"host"`
 	APIKey           string
-	accounts         map[string]types.Account
+	accounts         *account.Manager
 	DownloadUncached bool
 	client           *request.Client
+	downloadClient   *request.Client
 
 	MountPath   string
 	logger      zerolog.Logger
@@ -31,36 +33,34 @@ type AllDebrid struct {
 	addSamples  bool
 }
 
-func New(dc config.Debrid) *AllDebrid {
+func New(dc config.Debrid, opts ...request.ClientOption) *AllDebrid {
 	rl := request.ParseRateLimit(dc.RateLimit)
 
 	headers := map[string]string{
 		"Authorization": fmt.Sprintf("Bearer %s", dc.APIKey),
 	}
 	_log := logger.New(dc.Name)
-	client := request.New(
+	client := request.New(append([]request.ClientOption{
 		request.WithHeaders(headers),
 		request.WithLogger(_log),
 		request.WithRateLimiter(rl),
 		request.WithProxy(dc.Proxy),
-	)
-
-	accounts := make(map[string]types.Account)
-	for idx, key := range dc.DownloadAPIKeys {
-		id := strconv.Itoa(idx)
-		accounts[id] = types.Account{
-			Name:  key,
-			ID:    id,
-			Token: key,
-		}
-	}
+	}, opts...)...)
+
+	// The Authorization header is set per request, with the key of the picked account
+	downloadClient := request.New(append([]request.ClientOption{
+		request.WithLogger(_log),
+		request.WithRateLimiter(rl),
+		request.WithProxy(dc.Proxy),
+	}, opts...)...)
 	return &AllDebrid{
 		Name:             "alldebrid",
 		Host:             "http://api.alldebrid.com/v4.1",
 		APIKey:           dc.APIKey,
-		accounts:         accounts,
+		accounts:         account.NewManager(dc.Name, dc.DownloadAPIKeys),
 		DownloadUncached: dc.DownloadUncached,
 		client:           client,
+		downloadClient:   downloadClient,
 		MountPath:        dc.Folder,
 		logger:           logger.New(dc.Name),
 		checkCached:      dc.CheckCached,
@@ -80,8 +80,7 @@ func (ad *AllDebrid) IsAvailable(hashes []string) map[string]bool {
 	// Check if the infohashes are available in the local cache
 	result := make(map[string]bool)
 
-	// Divide hashes into groups of 100
-	// AllDebrid does not support checking cached infohashes
+	// AllDebrid does not support checking cached infohashes, none is answered for
 	return result
 }
 
@@ -91,7 +90,7 @@ func (ad *AllDebrid) SubmitMagnet(torrent *types.Torrent) (*types.Torrent, error
 	query.Add("magnets[]", torrent.Magnet.Link)
 	url += "?" + query.Encode()
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := ad.client.MakeRequest(req)
+	resp, err := makeRequest(ad.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +178,7 @@ func (ad *AllDebrid) flattenFiles(torrentId string, files []MagnetFile, parentPa
 func (ad *AllDebrid) GetTorrent(torrentId string) (*types.Torrent, error) {
 	url := fmt.Sprintf("%s/magnet/status?id=%s", ad.Host, torrentId)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := ad.client.MakeRequest(req)
+	resp, err := makeRequest(ad.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -221,7 +220,7 @@ func (ad *AllDebrid) GetTorrent(torrentId string) (*types.Torrent, error) {
 func (ad *AllDebrid) UpdateTorrent(t *types.Torrent) error {
 	url := fmt.Sprintf("%s/magnet/status?id=%s", ad.Host, t.Id)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := ad.client.MakeRequest(req)
+	resp, err := makeRequest(ad.client, req)
 	if err != nil {
 		return err
 	}
@@ -291,7 +290,7 @@ func (ad *AllDebrid) CheckStatus(torrent *types.Torrent, isSymlink bool) (*types
 func (ad *AllDebrid) DeleteTorrent(torrentId string) error {
 	url := fmt.Sprintf("%s/magnet/delete?id=%s", ad.Host, torrentId)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	if _, err := ad.client.MakeRequest(req); err != nil {
+	if _, err := makeRequest(ad.client, req); err != nil {
 		return err
 	}
 	ad.logger.Info().Msgf("Torrent %s deleted from AD", torrentId)
@@ -341,13 +340,22 @@ func (ad *AllDebrid) GenerateDownloadLinks(t *types.Torrent) error {
 	return nil
 }
 
+// GetDownloadLink unlocks the file with the least used download key, moving on to the next one
+// when a key is out of traffic
 func (ad *AllDebrid) GetDownloadLink(t *types.Torrent, file *types.File) (*types.DownloadLink, error) {
+	return account.Rotate(ad.accounts, "traffic exceeded", func(acc account.Account) (*types.DownloadLink, error) {
+		return ad.getDownloadLink(file, acc)
+	})
+}
+
+func (ad *AllDebrid) getDownloadLink(file *types.File, acc account.Account) (*types.DownloadLink, error) {
 	url := fmt.Sprintf("%s/link/unlock", ad.Host)
 	query := gourl.Values{}
 	query.Add("link", file.Link)
 	url += "?" + query.Encode()
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := ad.client.MakeRequest(req)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", acc.Token))
+	resp, err := makeRequest(ad.downloadClient, req)
 	if err != nil {
 		return nil, err
 	}
@@ -356,9 +364,6 @@ func (ad *AllDebrid) GetDownloadLink(t *types.Torrent, file *types.File) (*types
 		return nil, err
 	}
 
-	if data.Error != nil {
-		return nil, fmt.Errorf("error getting download link: %s", data.Error.Message)
-	}
 	link := data.Data.Link
 	if link == "" {
 		return nil, fmt.Errorf("download link is empty")
@@ -370,7 +375,7 @@ func (ad *AllDebrid) GetDownloadLink(t *types.Torrent, file *types.File) (*types
 		Size:         file.Size,
 		Filename:     file.Name,
 		Generated:    time.Now(),
-		AccountId:    "0",
+		AccountId:    acc.ID,
 	}, nil
 }
 
@@ -381,7 +386,7 @@ func (ad *AllDebrid) GetCheckCached() bool {
 func (ad *AllDebrid) GetTorrents() ([]*types.Torrent, error) {
 	url := fmt.Sprintf("%s/magnet/status?status=ready", ad.Host)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := ad.client.MakeRequest(req)
+	resp, err := makeRequest(ad.client, req)
 	torrents := make([]*types.Torrent, 0)
 	if err != nil {
 		return torrents, err
@@ -432,11 +437,18 @@ func (ad *AllDebrid) GetMountPath() string {
 }
 
 func (ad *AllDebrid) DisableAccount(accountId string) {
+	ad.accounts.Disable(accountId, "bandwidth exceeded")
 }
 
 func (ad *AllDebrid) ResetActiveDownloadKeys() {
+	ad.accounts.Reset()
+}
 
+func (ad *AllDebrid) Accounts() *account.Manager {
+	return ad.accounts
 }
+
+// DeleteDownloadLink is a no-op, unlocked links are not saved on the account
 func (ad *AllDebrid) DeleteDownloadLink(linkId string) error {
 	return nil
 }
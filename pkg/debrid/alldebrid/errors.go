@@ -0,0 +1,65 @@
+package alldebrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+// errorKinds maps AllDebrid error codes, see https://docs.alldebrid.com/#all-errors
+var errorKinds = map[string]*request.HTTPError{
+	"AUTH_MISSING_APIKEY":      request.AuthInvalidError,
+	"AUTH_BAD_APIKEY":          request.AuthInvalidError,
+	"AUTH_BLOCKED":             request.AuthInvalidError,
+	"AUTH_USER_BANNED":         request.AuthInvalidError,
+	"MUST_BE_PREMIUM":          request.AuthInvalidError,
+	"MAGNET_INVALID_ID":        request.TorrentNotFoundError,
+	"MAGNET_TOO_MANY_ACTIVE":   request.TooManyActiveDownloadsError,
+	"MAGNET_TOO_MANY":          request.TooManyActiveDownloadsError,
+	"LINK_TOO_MANY_DOWNLOADS":  request.TooManyActiveDownloadsError,
+	"LINK_HOST_LIMIT_REACHED":  request.TrafficExceededError,
+	"FREE_TRIAL_LIMIT_REACHED": request.TrafficExceededError,
+	"LINK_DOWN":                request.HosterUnavailableError,
+	"LINK_HOST_UNAVAILABLE":    request.ServiceUnavailableError,
+	"LINK_HOST_FULL":           request.ServiceUnavailableError,
+	"LINK_HOST_NOT_SUPPORTED":  request.UnknownError,
+	"TOO_MANY_REQUESTS":        request.RateLimitedError,
+}
+
+// apiError maps an AllDebrid error to a request error kind
+func apiError(statusCode int, e *errorResponse) error {
+	if e == nil {
+		return request.ProviderError(request.StatusError(statusCode), "alldebrid API error: Status: %d", statusCode)
+	}
+	kind, ok := errorKinds[e.Code]
+	if !ok {
+		kind = request.StatusError(statusCode)
+	}
+	return request.ProviderError(kind, "alldebrid API error: %s || %s", e.Code, e.Message)
+}
+
+// makeRequest is request.Client.MakeRequest, with AllDebrid error codes mapped.
+// AllDebrid answers most errors with a 200 and an error object.
+func makeRequest(client *request.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	var data struct {
+		Status string         `json:"status"`
+		Error  *errorResponse `json:"error"`
+	}
+	_ = json.Unmarshal(body, &data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || data.Status == "error" || data.Error != nil {
+		return nil, apiError(resp.StatusCode, data.Error)
+	}
+	return body, nil
+}
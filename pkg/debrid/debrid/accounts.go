@@ -0,0 +1,47 @@
+package debrid
+
+import (
+	"github.com/sirrobot01/decypharr/pkg/debrid/account"
+)
+
+// Accounts returns the download keys of a provider, or false if its keys are not managed
+func (d *Engine) Accounts(name string) ([]account.Account, bool) {
+	m := d.accountManager(name)
+	if m == nil {
+		return nil, false
+	}
+	return m.List(), true
+}
+
+// EnableAccount puts a download key disabled for the day back in rotation
+func (d *Engine) EnableAccount(name, id string) error {
+	m := d.accountManager(name)
+	if m == nil {
+		return account.ErrAccountNotFound
+	}
+	return m.Enable(id)
+}
+
+func (d *Engine) accountManager(name string) *account.Manager {
+	d.clientsMu.Lock()
+	client, ok := d.Clients[name]
+	d.clientsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	if p, ok := client.(account.Provider); ok {
+		return p.Accounts()
+	}
+	return nil
+}
+
+// RecordUsage adds bytes streamed from a file to the account its download link was generated with
+func (c *Cache) RecordUsage(fileLink string, bytes int64) {
+	p, ok := c.client.(account.Provider)
+	if !ok {
+		return
+	}
+	if dl, ok := c.downloadLinks.Load(fileLink); ok && dl.accountId != "" {
+		p.Accounts().RecordUsage(dl.accountId, bytes)
+	}
+}
@@ -0,0 +1,121 @@
+package debrid
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirrobot01/decypharr/pkg/arr"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+// availabilityTTL is how long an IsAvailable answer is reused.
+// Arrs often grab several releases of the same torrent in a row, there is no need to ask every time.
+const availabilityTTL = 5 * time.Minute
+
+// ErrNotCached is returned when a torrent is refused because no debrid has it cached
+var ErrNotCached = errors.New("torrent is not cached")
+
+type availabilityEntry struct {
+	cached    bool
+	expiresAt time.Time
+}
+
+// availabilityCache remembers which hashes each debrid has cached
+type availabilityCache struct {
+	mu      sync.Mutex
+	entries map[string]availabilityEntry // debrid:hash
+	ttl     time.Duration
+}
+
+func newAvailabilityCache(ttl time.Duration) *availabilityCache {
+	return &availabilityCache{
+		entries: make(map[string]availabilityEntry),
+		ttl:     ttl,
+	}
+}
+
+func availabilityKey(debrid, hash string) string {
+	return debrid + ":" + strings.ToLower(hash)
+}
+
+// lookup returns whether each hash is cached on the client.
+// Hashes that are not known yet are looked up with a single IsAvailable call. Only the answers are kept,
+// a hash the provider failed to check counts as not cached this time and is asked again next time.
+func (a *availabilityCache) lookup(name string, client types.Client, hashes []string) map[string]bool {
+	result := make(map[string]bool, len(hashes))
+	missing := make([]string, 0, len(hashes))
+
+	now := time.Now()
+	a.mu.Lock()
+	for _, h := range hashes {
+		if h == "" {
+			continue
+		}
+		if entry, ok := a.entries[availabilityKey(name, h)]; ok && now.Before(entry.expiresAt) {
+			result[h] = entry.cached
+		} else {
+			missing = append(missing, h)
+		}
+	}
+	a.mu.Unlock()
+
+	if len(missing) == 0 {
+		return result
+	}
+
+	available := client.IsAvailable(missing)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, entry := range a.entries {
+		if now.After(entry.expiresAt) {
+			delete(a.entries, key)
+		}
+	}
+	expiresAt := now.Add(a.ttl)
+	for _, h := range missing {
+		cached, ok := isHashCached(available, h)
+		if ok {
+			a.entries[availabilityKey(name, h)] = availabilityEntry{cached: cached, expiresAt: expiresAt}
+		}
+		result[h] = cached
+	}
+	return result
+}
+
+func (a *availabilityCache) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = make(map[string]availabilityEntry)
+}
+
+// IsAvailable reports, for every debrid accepting torrents from arrName, which of the hashes it has cached
+func (d *Engine) IsAvailable(hashes []string, arrName string) map[string]map[string]bool {
+	candidates := d.orderedClients(arrName)
+	result := make(map[string]map[string]bool, len(candidates))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range candidates {
+		wg.Add(1)
+		go func(c candidate) {
+			defer wg.Done()
+			available := d.availability.lookup(c.name, c.client, hashes)
+			mu.Lock()
+			result[c.name] = available
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return result
+}
+
+// cachedOnly reports whether uncached torrents from the arr must be refused by the client.
+// The arr's check_cached takes precedence over the debrid's.
+func cachedOnly(a *arr.Arr, client types.Client) bool {
+	if a != nil && a.CheckCached != nil {
+		return *a.CheckCached
+	}
+	return client.GetCheckCached()
+}
@@ -0,0 +1,52 @@
+package debrid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+// availabilityClient answers IsAvailable with a fixed result and counts the calls
+type availabilityClient struct {
+	types.Client
+	answers map[string]bool
+	calls   int
+}
+
+func (c *availabilityClient) IsAvailable(hashes []string) map[string]bool {
+	c.calls++
+	return c.answers
+}
+
+func TestAvailabilityCacheKeepsAnswers(t *testing.T) {
+	client := &availabilityClient{answers: map[string]bool{"AAA": true, "bbb": false}}
+	cache := newAvailabilityCache(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		got := cache.lookup("rd", client, []string{"aaa", "bbb"})
+		if !got["aaa"] || got["bbb"] {
+			t.Fatalf("lookup = %v, want aaa cached and bbb not", got)
+		}
+	}
+	if client.calls != 1 {
+		t.Errorf("IsAvailable called %d times, want the answers reused", client.calls)
+	}
+}
+
+func TestAvailabilityCacheSkipsUnanswered(t *testing.T) {
+	// A failed check answers for no hash
+	client := &availabilityClient{answers: map[string]bool{}}
+	cache := newAvailabilityCache(time.Minute)
+
+	if got := cache.lookup("rd", client, []string{"aaa"}); got["aaa"] {
+		t.Fatalf("lookup = %v, want aaa not cached", got)
+	}
+	client.answers = map[string]bool{"aaa": true}
+	if got := cache.lookup("rd", client, []string{"aaa"}); !got["aaa"] {
+		t.Errorf("lookup = %v, want aaa cached once the provider answers", got)
+	}
+	if client.calls != 2 {
+		t.Errorf("IsAvailable called %d times, want the failed check asked again", client.calls)
+	}
+}
@@ -1,7 +1,6 @@
 package debrid
 
 import (
-	"bufio"
 	"cmp"
 	"context"
 	"errors"
@@ -9,8 +8,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,7 +18,9 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/sirrobot01/decypharr/internal/config"
 	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/store"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 )
 
@@ -67,23 +66,37 @@ type RepairRequest struct {
 }
 
 type Cache struct {
+	// dir holds the <id>.json files of older versions, migrated into the store at start
 	dir    string
+	dbPath string
+	store  store.Store
 	client types.Client
 	logger zerolog.Logger
+	health *request.CircuitBreaker
 
 	torrents             *torrentCache
 	downloadLinks        *downloadLinkCache
+	warmer               *linkWarmer
 	invalidDownloadLinks sync.Map
 	folderNaming         WebDavFolderNaming
 
 	listingDebouncer *utils.Debouncer[bool]
 	// monitors
 	repairRequest        sync.Map
-	failedToReinsert     sync.Map
 	downloadLinkRequests sync.Map
 
 	// repair
-	repairChan chan RepairRequest
+	repairs       *repairQueue
+	repairWorkers int
+	scanner       healthScanner
+
+	// reinsert
+	reinsertPolicy   reinsertPolicy
+	reinsertFailures map[string]*ReinsertFailure
+	reinsertMu       sync.Mutex
+	// peers are the caches of the other debrids, tried when a reinsert gives up
+	peers   []*Cache
+	onMoved func(TorrentMove)
 
 	// readiness
 	ready chan struct{}
@@ -91,8 +104,11 @@ type Cache struct {
 	// config
 	workers                       int
 	torrentRefreshInterval        string
+	torrentFullRefreshInterval    string
 	downloadLinksRefreshInterval  string
 	autoExpiresLinksAfterDuration time.Duration
+	trashRetention                time.Duration
+	trashMaxSize                  int64
 
 	// refresh mutex
 	downloadLinksRefreshMu sync.RWMutex // for refreshing download links
@@ -117,43 +133,59 @@ func New(dc config.Debrid, client types.Client) *Cache {
 	if autoExpiresLinksAfter == 0 || err != nil {
 		autoExpiresLinksAfter = 48 * time.Hour
 	}
+	trashRetention, _ := time.ParseDuration(dc.TrashRetention)
+	trashMaxSize, _ := config.ParseSize(dc.TrashMaxSize)
+	healthScanInterval, _ := time.ParseDuration(dc.HealthScanInterval)
+	prefetchRecent, _ := time.ParseDuration(dc.LinkPrefetchRecent)
+	prefetchNew, _ := time.ParseDuration(dc.LinkPrefetchNew)
+	_log := logger.New(fmt.Sprintf("%s-webdav", client.GetName()))
 	var customFolders []string
-	dirFilters := map[string][]directoryFilter{}
+	dirFilters := map[string]*config.DirectoryFilter{}
 	for name, value := range dc.Directories {
-		for filterType, v := range value.Filters {
-			df := directoryFilter{filterType: filterType, value: v}
-			switch filterType {
-			case filterByRegex, filterByNotRegex:
-				df.regex = regexp.MustCompile(v)
-			case filterBySizeGT, filterBySizeLT:
-				df.sizeThreshold, _ = config.ParseSize(v)
-			case filterBLastAdded:
-				df.ageThreshold, _ = time.ParseDuration(v)
-			}
-			dirFilters[name] = append(dirFilters[name], df)
+		filter, err := value.Compile()
+		if err != nil {
+			_log.Error().Err(err).Msgf("Skipping folder %s", name)
+			continue
 		}
+		dirFilters[name] = filter
 		customFolders = append(customFolders, name)
-
 	}
-	_log := logger.New(fmt.Sprintf("%s-webdav", client.GetName()))
 	c := &Cache{
-		dir: filepath.Join(cfg.Path, "cache", dc.Name), // path to save cache files
+		dir:    filepath.Join(cfg.Path, "cache", dc.Name),
+		dbPath: filepath.Join(cfg.Path, "cache", dc.Name+".db"),
 
-		torrents:                      newTorrentCache(dirFilters),
+		torrents:                      newTorrentCache(dirFilters, dc.MediaLibrary),
 		client:                        client,
 		logger:                        _log,
 		workers:                       dc.Workers,
 		downloadLinks:                 newDownloadLinkCache(),
+		warmer:                        newLinkWarmer(dc.LinkPrefetchRate, prefetchRecent, prefetchNew),
 		torrentRefreshInterval:        dc.TorrentsRefreshInterval,
+		torrentFullRefreshInterval:    dc.TorrentsFullRefreshInterval,
 		downloadLinksRefreshInterval:  dc.DownloadLinksRefreshInterval,
 		folderNaming:                  WebDavFolderNaming(dc.FolderNaming),
 		autoExpiresLinksAfterDuration: autoExpiresLinksAfter,
+		trashRetention:                trashRetention,
+		trashMaxSize:                  trashMaxSize,
 		saveSemaphore:                 make(chan struct{}, 50),
 		cetScheduler:                  cetSc,
 		scheduler:                     scheduler,
 
 		config:        dc,
 		customFolders: customFolders,
+		repairs:       newRepairQueue(),
+		repairWorkers: max(dc.RepairWorkers, 1),
+		scanner: healthScanner{
+			interval:  healthScanInterval,
+			batchSize: dc.HealthScanBatchSize,
+		},
+		reinsertPolicy: reinsertPolicy{
+			maxAttempts: max(dc.ReinsertMaxAttempts, 1),
+			backoff:     parseBackoff(dc.ReinsertBackoff),
+			fallback:    dc.ReinsertFallback,
+			finalAction: ReinsertFinalAction(dc.ReinsertFinalAction),
+		},
+		reinsertFailures: make(map[string]*ReinsertFailure),
 
 		ready: make(chan struct{}),
 	}
@@ -188,19 +220,25 @@ func (c *Cache) Reset() {
 	// Stop the listing debouncer
 	c.listingDebouncer.Stop()
 
-	// Close the repair channel
-	close(c.repairChan)
+	if c.store != nil {
+		if err := c.store.Close(); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to close cache store")
+		}
+	}
+
+	// Release whoever waits on a repair, the workers are gone
+	c.repairs.cancel(errors.New("cache stopped"))
 
 	// 1. Reset torrent storage
 	c.torrents.reset()
 
 	// 2. Reset download-link cache
 	c.downloadLinks.reset()
+	c.warmer.reset()
 
 	// 3. Clear any sync.Maps
 	c.invalidDownloadLinks = sync.Map{}
 	c.repairRequest = sync.Map{}
-	c.failedToReinsert = sync.Map{}
 	c.downloadLinkRequests = sync.Map{}
 
 	// 5. Rebuild the listing debouncer
@@ -211,14 +249,21 @@ func (c *Cache) Reset() {
 		},
 	)
 
-	// 6. Reset repair channel so the next Start() can spin it up
-	c.repairChan = make(chan RepairRequest, 100)
+	// 6. Reset the repair queue so the next Start() can spin it up
+	c.repairs = newRepairQueue()
+	c.reinsertMu.Lock()
+	c.reinsertFailures = make(map[string]*ReinsertFailure)
+	c.reinsertMu.Unlock()
 }
 
 func (c *Cache) Start(ctx context.Context) error {
-	if err := os.MkdirAll(c.dir, 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
+	if err := c.openStore(); err != nil {
+		return err
 	}
+	c.purgeTrash()
+	c.loadScanState()
+	c.loadDownloadLinks()
+	c.loadReinsertFailures()
 
 	if err := c.Sync(ctx); err != nil {
 		return fmt.Errorf("failed to sync cache: %w", err)
@@ -231,8 +276,10 @@ func (c *Cache) Start(ctx context.Context) error {
 		c.logger.Error().Err(err).Msg("Failed to start cache worker")
 	}
 
-	c.repairChan = make(chan RepairRequest, 100)
-	go c.repairWorker(ctx)
+	c.loadRepairQueue()
+	for i := 0; i < c.repairWorkers; i++ {
+		go c.repairWorker(ctx)
+	}
 
 	// Fire the ready channel
 	close(c.ready)
@@ -248,37 +295,41 @@ func (c *Cache) Start(ctx context.Context) error {
 	return nil
 }
 
-func (c *Cache) load(ctx context.Context) (map[string]CachedTorrent, error) {
-	mu := sync.Mutex{}
-
-	if err := os.MkdirAll(c.dir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+// openStore opens the cache database, importing the JSON files of older versions on first start
+func (c *Cache) openStore() error {
+	if err := os.MkdirAll(filepath.Dir(c.dbPath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
-
-	files, err := os.ReadDir(c.dir)
+	s, err := store.OpenBolt(c.dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+		return fmt.Errorf("failed to open cache store: %w", err)
 	}
+	c.store = s
 
-	// Get only json files
-	var jsonFiles []os.DirEntry
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
-			jsonFiles = append(jsonFiles, file)
-		}
+	migrated, err := store.MigrateJSON(c.dir, s)
+	if err != nil {
+		c.logger.Error().Err(err).Msgf("Failed to migrate cache files from %s", c.dir)
+	} else if migrated > 0 {
+		c.logger.Info().Msgf("Migrated %d torrents from %s to %s", migrated, c.dir, c.dbPath)
 	}
+	return nil
+}
 
-	if len(jsonFiles) == 0 {
-		return nil, nil
+func (c *Cache) load(ctx context.Context) (map[string]CachedTorrent, error) {
+	mu := sync.Mutex{}
+
+	type entry struct {
+		id   string
+		data []byte
 	}
 
 	// Create channels with appropriate buffering
-	workChan := make(chan os.DirEntry, min(c.workers, len(jsonFiles)))
+	workChan := make(chan entry, c.workers)
 
 	// Create a wait group for workers
 	var wg sync.WaitGroup
 
-	torrents := make(map[string]CachedTorrent, len(jsonFiles))
+	torrents := make(map[string]CachedTorrent)
 
 	// Start workers
 	for i := 0; i < c.workers; i++ {
@@ -286,29 +337,16 @@ func (c *Cache) load(ctx context.Context) (map[string]CachedTorrent, error) {
 		go func() {
 			defer wg.Done()
 
-			for {
-				file, ok := <-workChan
-				if !ok {
-					return // Channel closed, exit goroutine
-				}
-
-				fileName := file.Name()
-				filePath := filepath.Join(c.dir, fileName)
-				data, err := os.ReadFile(filePath)
-				if err != nil {
-					c.logger.Error().Err(err).Msgf("Failed to read file: %s", filePath)
-					continue
-				}
-
+			for e := range workChan {
 				var ct CachedTorrent
-				if err := json.Unmarshal(data, &ct); err != nil {
-					c.logger.Error().Err(err).Msgf("Failed to unmarshal file: %s", filePath)
+				if err := json.Unmarshal(e.data, &ct); err != nil {
+					c.logger.Error().Err(err).Msgf("Failed to unmarshal torrent: %s", e.id)
 					continue
 				}
 
 				isComplete := true
 				if len(ct.Files) != 0 {
-					// Check if all files are valid, if not, delete the file.json and remove from cache.
+					// Check if all files are valid, if not, skip the torrent, it's processed again on sync
 					fs := make(map[string]types.File, len(ct.Files))
 					for _, f := range ct.Files {
 						if f.Link == "" {
@@ -337,14 +375,14 @@ func (c *Cache) load(ctx context.Context) (map[string]CachedTorrent, error) {
 	}
 
 	// Feed work to workers
-	for _, file := range jsonFiles {
+	err := c.store.ForEach(func(id string, data []byte) error {
 		select {
 		case <-ctx.Done():
-			break // Context cancelled
-		default:
-			workChan <- file
+			return ctx.Err()
+		case workChan <- entry{id: id, data: data}:
+			return nil
 		}
-	}
+	})
 
 	// Signal workers that no more work is coming
 	close(workChan)
@@ -352,6 +390,9 @@ func (c *Cache) load(ctx context.Context) (map[string]CachedTorrent, error) {
 	// Wait for all workers to complete
 	wg.Wait()
 
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache store: %w", err)
+	}
 	return torrents, nil
 }
 
@@ -566,82 +607,43 @@ func (c *Cache) GetTorrent(torrentId string) *CachedTorrent {
 
 func (c *Cache) SaveTorrents() {
 	torrents := c.torrents.getAll()
+	items := make(map[string][]byte, len(torrents))
 	for _, torrent := range torrents {
-		c.SaveTorrent(torrent)
+		marshaled, err := json.Marshal(torrent)
+		if err != nil {
+			c.logger.Error().Err(err).Msgf("Failed to marshal torrent: %s", torrent.Id)
+			continue
+		}
+		items[torrent.Id] = marshaled
+	}
+	if err := c.store.PutAll(items); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to save torrents")
 	}
 }
 
 func (c *Cache) SaveTorrent(ct CachedTorrent) {
-	marshaled, err := json.MarshalIndent(ct, "", "  ")
+	marshaled, err := json.Marshal(ct)
 	if err != nil {
 		c.logger.Error().Err(err).Msgf("Failed to marshal torrent: %s", ct.Id)
 		return
 	}
 
-	// Store just the essential info needed for the file operation
-	saveInfo := struct {
-		id       string
-		jsonData []byte
-	}{
-		id:       ct.Torrent.Id,
-		jsonData: marshaled,
-	}
-
+	id := ct.Torrent.Id
 	// Try to acquire semaphore without blocking
 	select {
 	case c.saveSemaphore <- struct{}{}:
 		go func() {
 			defer func() { <-c.saveSemaphore }()
-			c.saveTorrent(saveInfo.id, saveInfo.jsonData)
+			c.saveTorrent(id, marshaled)
 		}()
 	default:
-		c.saveTorrent(saveInfo.id, saveInfo.jsonData)
+		c.saveTorrent(id, marshaled)
 	}
 }
 
 func (c *Cache) saveTorrent(id string, data []byte) {
-
-	fileName := id + ".json"
-	filePath := filepath.Join(c.dir, fileName)
-
-	// Use a unique temporary filename for concurrent safety
-	tmpFile := filePath + ".tmp." + strconv.FormatInt(time.Now().UnixNano(), 10)
-
-	f, err := os.Create(tmpFile)
-	if err != nil {
-		c.logger.Error().Err(err).Msgf("Failed to create file: %s", tmpFile)
-		return
-	}
-
-	// Track if we've closed the file
-	fileClosed := false
-	defer func() {
-		// Only close if not already closed
-		if !fileClosed {
-			_ = f.Close()
-		}
-		// Clean up the temp file if it still exists and rename failed
-		_ = os.Remove(tmpFile)
-	}()
-
-	w := bufio.NewWriter(f)
-	if _, err := w.Write(data); err != nil {
-		c.logger.Error().Err(err).Msgf("Failed to write data: %s", tmpFile)
-		return
-	}
-
-	if err := w.Flush(); err != nil {
-		c.logger.Error().Err(err).Msgf("Failed to flush data: %s", tmpFile)
-		return
-	}
-
-	// Close the file before renaming
-	_ = f.Close()
-	fileClosed = true
-
-	if err := os.Rename(tmpFile, filePath); err != nil {
-		c.logger.Error().Err(err).Msgf("Failed to rename file: %s", tmpFile)
-		return
+	if err := c.store.Put(id, data); err != nil {
+		c.logger.Error().Err(err).Msgf("Failed to save torrent: %s", id)
 	}
 }
 
@@ -788,21 +790,9 @@ func (c *Cache) DeleteTorrents(ids []string) {
 }
 
 func (c *Cache) removeFromDB(torrentId string) {
-	// Moves the torrent file to the trash
-	filePath := filepath.Join(c.dir, torrentId+".json")
-
-	// Check if the file exists
-	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
-		return
-	}
-
-	// Move the file to the trash
-	trashPath := filepath.Join(c.dir, "trash", torrentId+".json")
-	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
-		return
-	}
-	if err := os.Rename(filePath, trashPath); err != nil {
-		return
+	// Moves the torrent to the trash
+	if err := c.store.Trash(torrentId); err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.logger.Error().Err(err).Msgf("Failed to trash torrent: %s", torrentId)
 	}
 }
 
@@ -818,3 +808,7 @@ func (c *Cache) OnRemove(torrentId string) {
 func (c *Cache) GetLogger() zerolog.Logger {
 	return c.logger
 }
+
+func (c *Cache) GetConfig() config.Debrid {
+	return c.config
+}
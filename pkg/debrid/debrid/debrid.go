@@ -1,30 +1,44 @@
 package debrid
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
 	"github.com/sirrobot01/decypharr/pkg/arr"
 	"github.com/sirrobot01/decypharr/pkg/debrid/alldebrid"
 	"github.com/sirrobot01/decypharr/pkg/debrid/debrid_link"
+	"github.com/sirrobot01/decypharr/pkg/debrid/generic"
+	"github.com/sirrobot01/decypharr/pkg/debrid/mock"
+	"github.com/sirrobot01/decypharr/pkg/debrid/premiumize"
 	"github.com/sirrobot01/decypharr/pkg/debrid/realdebrid"
 	"github.com/sirrobot01/decypharr/pkg/debrid/torbox"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"strings"
 )
 
-func createDebridClient(dc config.Debrid) types.Client {
+func createDebridClient(dc config.Debrid, opts ...request.ClientOption) (types.Client, error) {
+	if dc.Mapping != nil {
+		return generic.New(dc, *dc.Mapping, opts...), nil
+	}
 	switch dc.Name {
 	case "realdebrid":
-		return realdebrid.New(dc)
+		return realdebrid.New(dc, opts...), nil
 	case "torbox":
-		return torbox.New(dc)
+		return torbox.New(dc, opts...), nil
 	case "debridlink":
-		return debrid_link.New(dc)
+		return debrid_link.New(dc, opts...), nil
 	case "alldebrid":
-		return alldebrid.New(dc)
+		return alldebrid.New(dc, opts...), nil
+	case "premiumize":
+		return premiumize.New(dc, opts...), nil
+	case "mock":
+		return mock.New(dc, opts...), nil
+	case "offcloud":
+		return generic.New(dc, generic.Offcloud, opts...), nil
 	default:
-		return realdebrid.New(dc)
+		return nil, fmt.Errorf("unknown debrid provider: %s", dc.Name)
 	}
 }
 
@@ -52,34 +66,44 @@ func ProcessTorrent(d *Engine, magnet *utils.Magnet, a *arr.Arr, isSymlink, over
 		debridTorrent.DownloadUncached = false
 	}
 
-	for index, db := range d.Clients {
+	candidates := d.selectClients(debridTorrent.InfoHash, a.Name)
+	// notCached holds the candidates refused before submitting, the others tried before failed
+	notCached := make(map[string]bool)
+	for i, c := range candidates {
+		db := c.client
 		logger := db.GetLogger()
-		logger.Info().Str("Debrid", db.GetName()).Str("Hash", debridTorrent.InfoHash).Msg("Processing torrent")
+		logger.Info().Str("Debrid", db.GetName()).Str("Hash", debridTorrent.InfoHash).Bool("Cached", c.cached).Msg("Processing torrent")
 
 		if !overrideDownloadUncached && a.DownloadUncached == nil {
 			debridTorrent.DownloadUncached = db.GetDownloadUncached()
 		}
 
-		//if db.GetCheckCached() {
-		//	hash, exists := db.IsAvailable([]string{debridTorrent.InfoHash})[debridTorrent.InfoHash]
-		//	if !exists || !hash {
-		//		logger.Info().Msgf("Torrent: %s is not cached", debridTorrent.Name)
-		//		continue
-		//	} else {
-		//		logger.Info().Msgf("Torrent: %s is cached(or downloading)", debridTorrent.Name)
-		//	}
-		//}
+		if !c.cached && !overrideDownloadUncached && cachedOnly(a, db) {
+			// Refuse before submitting, uncached torrents only take up active slots
+			logger.Info().Msgf("Torrent: %s is not cached on %s, skipping", debridTorrent.Name, c.name)
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, ErrNotCached))
+			notCached[c.name] = true
+			continue
+		}
 
 		dbt, err := db.SubmitMagnet(debridTorrent)
 		if err != nil || dbt == nil || dbt.Id == "" {
+			if err == nil {
+				err = fmt.Errorf("%s: failed to submit magnet", c.name)
+			}
+			d.selector.markFailed(c.name)
 			errs = append(errs, err)
 			continue
 		}
 		dbt.Arr = a
-		logger.Info().Str("id", dbt.Id).Msgf("Torrent: %s submitted to %s", dbt.Name, db.GetName())
-		d.LastUsed = index
+		dbt.Cached = c.cached
+		dbt.SelectionReason = selectionReason(c, candidates[:i], notCached, d.selector.strategy)
+		logger.Info().Str("id", dbt.Id).Str("reason", dbt.SelectionReason).Msgf("Torrent: %s submitted to %s", dbt.Name, db.GetName())
 
 		torrent, err := db.CheckStatus(dbt, isSymlink)
+		if err != nil {
+			d.selector.markFailed(c.name)
+		}
 		if err != nil && torrent != nil && torrent.Id != "" {
 			// Delete the torrent if it was not downloaded
 			go func(id string) {
@@ -89,15 +113,65 @@ func ProcessTorrent(d *Engine, magnet *utils.Magnet, a *arr.Arr, isSymlink, over
 		return torrent, err
 	}
 	if len(errs) == 0 {
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("failed to process torrent: no debrid accepts torrents from %s", a.Name)
+		}
 		return nil, fmt.Errorf("failed to process torrent: no clients available")
 	}
+	if allNotCached(errs) {
+		return nil, fmt.Errorf("failed to process torrent: %w", ErrNotCached)
+	}
 	if len(errs) == 1 {
 		return nil, fmt.Errorf("failed to process torrent: %w", errs[0])
+	}
+	return nil, fmt.Errorf("failed to process torrent: %w", providerErrors(errs))
+}
+
+// providerErrors joins the errors of every provider tried, keeping their kinds reachable with errors.Is
+type providerErrors []error
+
+func (e providerErrors) Error() string {
+	errStrings := make([]string, 0, len(e))
+	for _, err := range e {
+		errStrings = append(errStrings, err.Error())
+	}
+	return strings.Join(errStrings, ", ")
+}
+
+func (e providerErrors) Unwrap() []error {
+	return e
+}
+
+func allNotCached(errs []error) bool {
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotCached) {
+			return false
+		}
+	}
+	return len(errs) > 0
+}
+
+// selectionReason describes why a client ended up with a torrent.
+// skipped holds the candidates that came before it, they failed unless notCached refused them.
+func selectionReason(c candidate, skipped []candidate, notCached map[string]bool, strategy string) string {
+	var reason string
+	if c.reason == selectionReasonCached {
+		reason = fmt.Sprintf("cached on %s", c.name)
+	} else if len(skipped) > 0 && skipped[0].cached {
+		reason = fmt.Sprintf("not cached on %s, next by %s selection", c.name, strategy)
 	} else {
-		errStrings := make([]string, 0, len(errs))
-		for _, err := range errs {
-			errStrings = append(errStrings, err.Error())
+		reason = fmt.Sprintf("not cached on any provider, %s picked by %s selection", c.name, strategy)
+	}
+	if len(skipped) > 0 {
+		outcomes := make([]string, 0, len(skipped))
+		for _, s := range skipped {
+			if notCached[s.name] {
+				outcomes = append(outcomes, s.name+" skipped (not cached)")
+			} else {
+				outcomes = append(outcomes, s.name+" failed")
+			}
 		}
-		return nil, fmt.Errorf("failed to process torrent: %s", strings.Join(errStrings, ", "))
+		reason = fmt.Sprintf("%s (after %s)", reason, strings.Join(outcomes, ", "))
 	}
+	return reason
 }
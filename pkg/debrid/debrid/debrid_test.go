@@ -0,0 +1,106 @@
+package debrid
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/arr"
+)
+
+const (
+	cachedHash   = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	uncachedHash = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// TestMain configures a single mock debrid, checking the cache, with two 1KB files per torrent
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "debrid")
+	if err != nil {
+		panic(err)
+	}
+	cfg := `{
+		"log_level": "error",
+		"debrids": [{
+			"name": "mock",
+			"folder": "` + filepath.ToSlash(dir) + `",
+			"check_cached": true,
+			"mock": {"cached_hashes": ["` + cachedHash + `"], "files": 2, "file_size": "1KB"}
+		}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(cfg), 0644); err != nil {
+		panic(err)
+	}
+	config.SetConfigPath(dir)
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestProcessTorrentCached(t *testing.T) {
+	engine := NewEngine()
+	t.Cleanup(engine.Reset)
+
+	magnet := &utils.Magnet{InfoHash: cachedHash, Name: "Movie.2020"}
+	torrent, err := ProcessTorrent(engine, magnet, &arr.Arr{Name: "radarr"}, false, false)
+	if err != nil {
+		t.Fatalf("ProcessTorrent: %v", err)
+	}
+	if torrent.Debrid != "mock" || torrent.Status != "downloaded" || !torrent.Cached {
+		t.Errorf("unexpected torrent %+v", torrent)
+	}
+	if len(torrent.Files) != 2 {
+		t.Fatalf("got %d files, want 2", len(torrent.Files))
+	}
+	for name, f := range torrent.Files {
+		if f.DownloadLink == nil {
+			t.Fatalf("file %s has no download link", name)
+		}
+		resp, err := http.Get(f.DownloadLink.DownloadLink)
+		if err != nil {
+			t.Fatalf("download %s: %v", name, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil || int64(len(body)) != f.Size {
+			t.Errorf("download %s: read %d bytes, %v, want %d", name, len(body), err, f.Size)
+		}
+	}
+}
+
+func TestProcessTorrentNotCached(t *testing.T) {
+	engine := NewEngine()
+	t.Cleanup(engine.Reset)
+
+	magnet := &utils.Magnet{InfoHash: uncachedHash, Name: "Movie.2021"}
+	if _, err := ProcessTorrent(engine, magnet, &arr.Arr{Name: "radarr"}, false, false); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("ProcessTorrent error = %v, want %v", err, ErrNotCached)
+	}
+	torrents, err := engine.GetClient("mock").GetTorrents()
+	if err != nil || len(torrents) != 0 {
+		t.Errorf("GetTorrents = %d torrents, %v, want nothing submitted", len(torrents), err)
+	}
+}
+
+func TestResetClosesMockServer(t *testing.T) {
+	engine := NewEngine()
+	magnet := &utils.Magnet{InfoHash: cachedHash, Name: "Movie.2020"}
+	torrent, err := ProcessTorrent(engine, magnet, &arr.Arr{Name: "radarr"}, false, false)
+	if err != nil {
+		t.Fatalf("ProcessTorrent: %v", err)
+	}
+	engine.Reset()
+
+	for name, f := range torrent.Files {
+		resp, err := http.Get(f.DownloadLink.DownloadLink)
+		if err == nil {
+			_ = resp.Body.Close()
+			t.Errorf("download %s succeeded after Reset, want the file server closed", name)
+		}
+	}
+}
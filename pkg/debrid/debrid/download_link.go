@@ -1,9 +1,11 @@
 package debrid
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/pkg/debrid/store"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"sync"
 	"time"
@@ -74,6 +76,8 @@ func (r *downloadLinkRequest) Wait() (string, error) {
 }
 
 func (c *Cache) GetDownloadLink(torrentName, filename, fileLink string) (string, error) {
+	c.warmer.touch(torrentName, filename, fileLink)
+
 	// Check link cache
 	if dl := c.checkDownloadLink(fileLink); dl != "" {
 		return dl, nil
@@ -89,7 +93,9 @@ func (c *Cache) GetDownloadLink(torrentName, filename, fileLink string) (string,
 	req := newDownloadLinkRequest()
 	c.downloadLinkRequests.Store(fileLink, req)
 
+	c.warmer.interactive.Add(1)
 	downloadLink, err := c.fetchDownloadLink(torrentName, filename, fileLink)
+	c.warmer.interactive.Add(-1)
 
 	// Complete the request and remove it from the map
 	req.Complete(downloadLink, err)
@@ -118,7 +124,7 @@ func (c *Cache) fetchDownloadLink(torrentName, filename, fileLink string) (strin
 	// If file.Link is still empty, return
 	if file.Link == "" {
 		// Try to reinsert the torrent?
-		newCt, err := c.reInsertTorrent(ct)
+		newCt, err := c.repairAndWait(ct, RepairPriorityInteractive)
 		if err != nil {
 			return "", fmt.Errorf("failed to reinsert torrent. %w", err)
 		}
@@ -129,8 +135,8 @@ func (c *Cache) fetchDownloadLink(torrentName, filename, fileLink string) (strin
 	c.logger.Trace().Msgf("Getting download link for %s(%s)", filename, file.Link)
 	downloadLink, err := c.client.GetDownloadLink(ct.Torrent, &file)
 	if err != nil {
-		if errors.Is(err, request.HosterUnavailableError) {
-			newCt, err := c.reInsertTorrent(ct)
+		if isLinkBroken(err) {
+			newCt, err := c.repairAndWait(ct, RepairPriorityInteractive)
 			if err != nil {
 				return "", fmt.Errorf("failed to reinsert torrent: %w", err)
 			}
@@ -145,7 +151,7 @@ func (c *Cache) fetchDownloadLink(torrentName, filename, fileLink string) (strin
 				return "", fmt.Errorf("download link is empty for")
 			}
 			c.updateDownloadLink(downloadLink)
-			return "", nil
+			return downloadLink.DownloadLink, nil
 		} else if errors.Is(err, request.TrafficExceededError) {
 			// This is likely a fair usage limit error
 			return "", err
@@ -174,12 +180,14 @@ func (c *Cache) GenerateDownloadLinks(t CachedTorrent) {
 }
 
 func (c *Cache) updateDownloadLink(dl *types.DownloadLink) {
-	c.downloadLinks.Store(dl.Link, linkCache{
+	lc := linkCache{
 		Id:        dl.Id,
 		link:      dl.DownloadLink,
 		expiresAt: time.Now().Add(c.autoExpiresLinksAfterDuration),
 		accountId: dl.AccountId,
-	})
+	}
+	c.downloadLinks.Store(dl.Link, lc)
+	c.saveDownloadLinks(map[string]linkCache{dl.Link: lc})
 }
 
 func (c *Cache) checkDownloadLink(link string) string {
@@ -191,10 +199,13 @@ func (c *Cache) checkDownloadLink(link string) string {
 	return ""
 }
 
-func (c *Cache) MarkDownloadLinkAsInvalid(link, downloadLink, reason string) {
-	c.invalidDownloadLinks.Store(downloadLink, reason)
+// MarkDownloadLinkAsInvalid stops serving a download link until the daily reset. reason is the request error
+// kind the link failed with, the account behind it is disabled when it is out of traffic.
+func (c *Cache) MarkDownloadLinkAsInvalid(link, downloadLink string, reason error) {
+	c.invalidDownloadLinks.Store(downloadLink, request.ErrorCode(reason))
+	c.saveInvalidLink(downloadLink, request.ErrorCode(reason))
 	// Remove the download api key from active
-	if reason == "bandwidth_exceeded" {
+	if errors.Is(reason, request.TrafficExceededError) {
 		if dl, ok := c.downloadLinks.Load(link); ok {
 			if dl.accountId != "" && dl.link == downloadLink {
 				c.client.DisableAccount(dl.accountId)
@@ -208,6 +219,7 @@ func (c *Cache) removeDownloadLink(link string) {
 	if dl, ok := c.downloadLinks.Load(link); ok {
 		// Delete dl from cache
 		c.downloadLinks.Delete(link)
+		c.deleteSavedLinks(link)
 		// Delete dl from debrid
 		if dl.Id != "" {
 			_ = c.client.DeleteDownloadLink(dl.Id)
@@ -222,3 +234,105 @@ func (c *Cache) IsDownloadLinkInvalid(downloadLink string) bool {
 	}
 	return false
 }
+
+// savedLink is how a download link is kept in the store
+type savedLink struct {
+	Id        string    `json:"id"`
+	Link      string    `json:"link"`
+	AccountId string    `json:"account_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// savedInvalidLink is how an invalid download link is kept in the store
+type savedInvalidLink struct {
+	Reason    string    `json:"reason"`
+	InvalidAt time.Time `json:"invalid_at"`
+}
+
+func (c *Cache) saveDownloadLinks(links map[string]linkCache) {
+	items := make(map[string][]byte, len(links))
+	for key, lc := range links {
+		data, err := json.Marshal(savedLink{Id: lc.Id, Link: lc.link, AccountId: lc.accountId, ExpiresAt: lc.expiresAt})
+		if err != nil {
+			continue
+		}
+		items[key] = data
+	}
+	if err := c.store.PutLinks(store.Links, items); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to save download links")
+	}
+}
+
+func (c *Cache) deleteSavedLinks(keys ...string) {
+	if err := c.store.DeleteLinks(store.Links, keys...); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to delete saved download links")
+	}
+}
+
+func (c *Cache) saveInvalidLink(downloadLink, reason string) {
+	data, err := json.Marshal(savedInvalidLink{Reason: reason, InvalidAt: time.Now()})
+	if err != nil {
+		return
+	}
+	if err := c.store.PutLinks(store.InvalidLinks, map[string][]byte{downloadLink: data}); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to save invalid download link")
+	}
+}
+
+// loadDownloadLinks restores the download links saved by the last run, dropping the expired ones. Invalid
+// links are kept until the next daily reset.
+func (c *Cache) loadDownloadLinks() {
+	now := time.Now()
+	expired := make([]string, 0)
+	loaded := 0
+	err := c.store.ForEachLink(store.Links, func(key string, data []byte) error {
+		var sl savedLink
+		if err := json.Unmarshal(data, &sl); err != nil || !sl.ExpiresAt.After(now) {
+			expired = append(expired, key)
+			return nil
+		}
+		c.downloadLinks.Store(key, linkCache{
+			Id:        sl.Id,
+			link:      sl.Link,
+			accountId: sl.AccountId,
+			expiresAt: sl.ExpiresAt,
+		})
+		loaded++
+		return nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to load download links")
+		return
+	}
+	c.deleteSavedLinks(expired...)
+
+	// Invalid links are reset at midnight CET, drop the ones from before the last reset
+	cet, err := time.LoadLocation("CET")
+	if err != nil {
+		cet = time.UTC
+	}
+	y, m, d := now.In(cet).Date()
+	lastReset := time.Date(y, m, d, 0, 0, 0, 0, cet)
+	stale := make([]string, 0)
+	invalid := 0
+	err = c.store.ForEachLink(store.InvalidLinks, func(key string, data []byte) error {
+		var sl savedInvalidLink
+		if err := json.Unmarshal(data, &sl); err != nil || sl.InvalidAt.Before(lastReset) {
+			stale = append(stale, key)
+			return nil
+		}
+		c.invalidDownloadLinks.Store(key, sl.Reason)
+		invalid++
+		return nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to load invalid download links")
+		return
+	}
+	if err := c.store.DeleteLinks(store.InvalidLinks, stale...); err != nil {
+		c.logger.Debug().Err(err).Msg("Failed to delete stale invalid download links")
+	}
+	if loaded > 0 || invalid > 0 {
+		c.logger.Info().Msgf("Loaded %d download links, %d invalid", loaded, invalid)
+	}
+}
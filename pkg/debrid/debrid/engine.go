@@ -2,7 +2,10 @@ package debrid
 
 import (
 	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+	"io"
 	"sync"
 )
 
@@ -11,35 +14,97 @@ type Engine struct {
 	clientsMu sync.Mutex
 	Caches    map[string]*Cache
 	CacheMu   sync.Mutex
-	LastUsed  string
+
+	selector     *selector
+	health       map[string]*request.CircuitBreaker
+	availability *availabilityCache
+
+	movedMu        sync.Mutex
+	movedListeners []func(TorrentMove)
+}
+
+// TorrentMove is a torrent a repair reinserted on another debrid
+type TorrentMove struct {
+	InfoHash string
+	From     string // debrid names
+	To       string
+	OldID    string
+	NewID    string
+	// OldPath and NewPath are the folders of the torrent in the mounts
+	OldPath string
+	NewPath string
 }
 
 func NewEngine() *Engine {
 	cfg := config.Get()
+	_log := logger.Default()
 	clients := make(map[string]types.Client)
 
 	caches := make(map[string]*Cache)
+	health := make(map[string]*request.CircuitBreaker)
 
 	for _, dc := range cfg.Debrids {
-		client := createDebridClient(dc)
+		cb := newHealthTracker()
+		client, err := createDebridClient(dc, request.WithCircuitBreaker(cb))
+		if err != nil {
+			_log.Error().Err(err).Msg("Failed to create debrid client")
+			continue
+		}
 		logger := client.GetLogger()
 		if dc.UseWebDav {
 			caches[dc.Name] = New(dc, client)
+			caches[dc.Name].health = cb
 			logger.Info().Msg("Debrid Service started with WebDAV")
 		} else {
 			logger.Info().Msg("Debrid Service started")
 		}
 		clients[dc.Name] = client
+		health[dc.Name] = cb
+	}
+
+	// Reinserts that give up try the other debrids, in config order
+	for _, dc := range cfg.Debrids {
+		cache, ok := caches[dc.Name]
+		if !ok {
+			continue
+		}
+		for _, other := range cfg.Debrids {
+			if peer, ok := caches[other.Name]; ok && peer != cache {
+				cache.peers = append(cache.peers, peer)
+			}
+		}
 	}
 
 	d := &Engine{
 		Clients:  clients,
-		LastUsed: "",
 		Caches:   caches,
+		selector: newSelector(cfg.DebridSelection, cfg.Debrids),
+		health:   health,
+
+		availability: newAvailabilityCache(availabilityTTL),
+	}
+	for _, cache := range caches {
+		cache.onMoved = d.torrentMoved
 	}
 	return d
 }
 
+// OnTorrentMoved registers fn to be called when a repair moves a torrent to another debrid
+func (d *Engine) OnTorrentMoved(fn func(TorrentMove)) {
+	d.movedMu.Lock()
+	defer d.movedMu.Unlock()
+	d.movedListeners = append(d.movedListeners, fn)
+}
+
+func (d *Engine) torrentMoved(m TorrentMove) {
+	d.movedMu.Lock()
+	listeners := append([]func(TorrentMove){}, d.movedListeners...)
+	d.movedMu.Unlock()
+	for _, fn := range listeners {
+		fn(m)
+	}
+}
+
 func (d *Engine) GetClient(name string) types.Client {
 	d.clientsMu.Lock()
 	defer d.clientsMu.Unlock()
@@ -48,12 +113,28 @@ func (d *Engine) GetClient(name string) types.Client {
 
 func (d *Engine) Reset() {
 	d.clientsMu.Lock()
+	for name, client := range d.Clients {
+		// Clients holding resources, e.g. the mock's file server
+		if closer, ok := client.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				_log := client.GetLogger()
+				_log.Error().Err(err).Msgf("Failed to close %s", name)
+			}
+		}
+	}
 	d.Clients = make(map[string]types.Client)
+	d.selector = newSelector(config.DebridSelectionPriority, nil)
+	d.health = make(map[string]*request.CircuitBreaker)
+	d.availability.reset()
 	d.clientsMu.Unlock()
 
 	d.CacheMu.Lock()
 	d.Caches = make(map[string]*Cache)
 	d.CacheMu.Unlock()
+
+	d.movedMu.Lock()
+	d.movedListeners = nil
+	d.movedMu.Unlock()
 }
 
 func (d *Engine) GetDebrids() map[string]types.Client {
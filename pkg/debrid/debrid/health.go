@@ -0,0 +1,54 @@
+package debrid
+
+import (
+	"time"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+const (
+	// healthFailureThreshold is the number of consecutive server errors or timeouts before a provider is skipped
+	healthFailureThreshold = 5
+	// healthOpenDuration is how long a provider is skipped before it is probed again
+	healthOpenDuration = time.Minute
+)
+
+func newHealthTracker() *request.CircuitBreaker {
+	return request.NewCircuitBreaker(healthFailureThreshold, healthOpenDuration)
+}
+
+// IsHealthy reports whether requests to the provider are going through.
+// A provider with an open circuit is skipped until it can be probed again.
+func (d *Engine) IsHealthy(name string) bool {
+	d.clientsMu.Lock()
+	cb, ok := d.health[name]
+	d.clientsMu.Unlock()
+	return !ok || !cb.IsOpen()
+}
+
+// Health returns the health of every provider
+func (d *Engine) Health() map[string]request.BreakerStats {
+	d.clientsMu.Lock()
+	defer d.clientsMu.Unlock()
+	stats := make(map[string]request.BreakerStats, len(d.health))
+	for name, cb := range d.health {
+		stats[name] = cb.Stats()
+	}
+	return stats
+}
+
+// ResetHealth closes a provider's circuit, so it is used again straight away
+func (d *Engine) ResetHealth(name string) bool {
+	d.clientsMu.Lock()
+	cb, ok := d.health[name]
+	d.clientsMu.Unlock()
+	if ok {
+		cb.Reset()
+	}
+	return ok
+}
+
+// isHealthy reports whether the cache's provider is reachable
+func (c *Cache) isHealthy() bool {
+	return c.health == nil || !c.health.IsOpen()
+}
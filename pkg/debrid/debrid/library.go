@@ -0,0 +1,204 @@
+package debrid
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirrobot01/decypharr/internal/utils"
+)
+
+const (
+	LibraryMovies = "movies"
+	LibraryShows  = "shows"
+)
+
+// libraryFile points a file of the library at the file of a torrent
+type libraryFile struct {
+	torrentName string
+	filename    string
+	info        *fileInfo
+}
+
+// mediaLibrary is the parsed view of the torrents, movies/<Title (Year)>/<file> and shows/<Title>/Season NN/<file>.
+// Releases of the same movie or show share their folder.
+type mediaLibrary struct {
+	dirs  map[string][]os.FileInfo // keyed by path, e.g. "shows/The Show/Season 01"
+	files map[string]libraryFile   // keyed by path
+}
+
+func newMediaLibrary() *mediaLibrary {
+	return &mediaLibrary{
+		dirs: map[string][]os.FileInfo{
+			LibraryMovies: {},
+			LibraryShows:  {},
+		},
+		files: make(map[string]libraryFile),
+	}
+}
+
+// buildMediaLibrary parses the torrents and their files into the library. Bad torrents, samples and files
+// that aren't media are left out. When two releases have a file with the same name, the newest wins.
+func buildMediaLibrary(torrents map[string]CachedTorrent) *mediaLibrary {
+	lib := newMediaLibrary()
+	// folder keys are case-insensitive so releases spelled differently still group
+	folders := make(map[string]string)
+	folder := func(key, name string) string {
+		key = strings.ToLower(key)
+		if f, ok := folders[key]; ok {
+			return f
+		}
+		folders[key] = name
+		return name
+	}
+
+	// walk the torrents in order so the folder names don't change between refreshes
+	names := make([]string, 0, len(torrents))
+	for name := range torrents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, torrentName := range names {
+		t := torrents[torrentName]
+		if t.Bad {
+			continue
+		}
+		release := utils.ParseRelease(torrentName)
+		for filename, file := range t.Files {
+			if !utils.IsMediaFile(filename) || utils.IsSampleFile(filename) {
+				continue
+			}
+			episode := utils.ParseRelease(filename)
+			var dir string
+			switch {
+			case release.IsShow() || episode.IsShow():
+				title := cmp.Or(release.Title, episode.Title)
+				if !release.IsShow() {
+					// e.g. a complete series, the files know better
+					title = cmp.Or(episode.Title, release.Title)
+				}
+				title = libraryName(title)
+				if title == "" {
+					continue
+				}
+				season := cmp.Or(episode.Season, release.Season)
+				dir = path.Join(LibraryShows, folder(path.Join(LibraryShows, title), title), fmt.Sprintf("Season %02d", season))
+			default:
+				title := libraryName(release.Title)
+				if title == "" {
+					continue
+				}
+				if release.Year > 0 {
+					title = fmt.Sprintf("%s (%d)", title, release.Year)
+				}
+				dir = path.Join(LibraryMovies, folder(path.Join(LibraryMovies, title), title))
+			}
+			lib.add(dir, libraryFile{
+				torrentName: torrentName,
+				filename:    filename,
+				info: &fileInfo{
+					id:      t.Id,
+					name:    file.Name,
+					size:    file.Size,
+					mode:    0644,
+					modTime: t.AddedOn,
+				},
+			})
+		}
+	}
+
+	for dir, children := range lib.dirs {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Name() < children[j].Name()
+		})
+		lib.dirs[dir] = children
+	}
+	return lib
+}
+
+// add adds a file and the folders above it
+func (l *mediaLibrary) add(dir string, f libraryFile) {
+	p := path.Join(dir, f.info.name)
+	if existing, ok := l.files[p]; ok {
+		if !f.info.modTime.After(existing.info.modTime) {
+			return
+		}
+		l.files[p] = f
+		children := l.dirs[dir]
+		for i, c := range children {
+			if c.Name() == f.info.name {
+				children[i] = f.info
+			}
+		}
+		return
+	}
+	l.files[p] = f
+	for {
+		_, exists := l.dirs[dir]
+		l.dirs[dir] = append(l.dirs[dir], f.info)
+		if exists || !strings.Contains(dir, "/") {
+			return
+		}
+		// first file of the folder, list it in its parent
+		parent := path.Dir(dir)
+		f = libraryFile{info: &fileInfo{
+			name:    path.Base(dir),
+			mode:    0755 | os.ModeDir,
+			modTime: f.info.modTime,
+			isDir:   true,
+		}}
+		dir = parent
+	}
+}
+
+// libraryName makes a title safe to be a folder name
+func libraryName(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return ' '
+		}
+		return r
+	}, title)
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// IsLibraryPath reports whether a path, relative to the WebDAV root of the debrid, is in the media library
+func (c *Cache) IsLibraryPath(p string) bool {
+	if !c.config.MediaLibrary {
+		return false
+	}
+	root, _, _ := strings.Cut(p, "/")
+	return root == LibraryMovies || root == LibraryShows
+}
+
+// GetLibraryFolders returns the top level folders of the media library, none when it's disabled
+func (c *Cache) GetLibraryFolders() []string {
+	if !c.config.MediaLibrary {
+		return nil
+	}
+	return []string{LibraryMovies, LibraryShows}
+}
+
+// GetLibraryListing returns the listing of a folder of the media library(READ-ONLY)
+func (c *Cache) GetLibraryListing(p string) ([]os.FileInfo, bool) {
+	lib := c.torrents.getLibrary()
+	if lib == nil {
+		return nil, false
+	}
+	children, ok := lib.dirs[p]
+	return children, ok
+}
+
+// GetLibraryFile returns the torrent folder and the name in the torrent of a file of the media library
+func (c *Cache) GetLibraryFile(p string) (string, string, bool) {
+	lib := c.torrents.getLibrary()
+	if lib == nil {
+		return "", "", false
+	}
+	f, ok := lib.files[p]
+	return f.torrentName, f.filename, ok
+}
@@ -0,0 +1,165 @@
+package debrid
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+	"golang.org/x/time/rate"
+)
+
+// linkWarmMargin is how long before their expiry warm links are refreshed
+const linkWarmMargin = time.Hour
+
+type warmFile struct {
+	torrentName string
+	filename    string
+	openedAt    time.Time
+}
+
+// linkWarmer refreshes the download links of recently opened files and new torrents in the background,
+// so playback doesn't wait on the debrid
+type linkWarmer struct {
+	mu     sync.Mutex
+	recent map[string]warmFile // keyed by file link
+	// limiter is the budget of the warmer, nil disables it
+	limiter      *rate.Limiter
+	recentWindow time.Duration
+	newWindow    time.Duration
+	// interactive counts the links being fetched for a reader, the warmer holds off meanwhile
+	interactive atomic.Int32
+}
+
+func newLinkWarmer(perMinute int, recentWindow, newWindow time.Duration) *linkWarmer {
+	w := &linkWarmer{
+		recent:       make(map[string]warmFile),
+		recentWindow: recentWindow,
+		newWindow:    newWindow,
+	}
+	if perMinute > 0 && (recentWindow > 0 || newWindow > 0) {
+		w.limiter = rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+	}
+	return w
+}
+
+// touch records a file being opened
+func (w *linkWarmer) touch(torrentName, filename, fileLink string) {
+	if w.recentWindow <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.recent[fileLink] = warmFile{torrentName: torrentName, filename: filename, openedAt: time.Now()}
+}
+
+// recentFiles returns the files opened within the window, most recent first, and forgets the older ones
+func (w *linkWarmer) recentFiles() []warmFile {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	files := make([]warmFile, 0, len(w.recent))
+	for link, f := range w.recent {
+		if time.Since(f.openedAt) > w.recentWindow {
+			delete(w.recent, link)
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].openedAt.After(files[j].openedAt)
+	})
+	return files
+}
+
+func (w *linkWarmer) reset() {
+	w.mu.Lock()
+	w.recent = make(map[string]warmFile)
+	w.mu.Unlock()
+}
+
+// warmLinks refreshes the links of recently opened files, then of new torrents, that are missing or about
+// to expire. It stops when its budget runs out or a reader is waiting on a link.
+func (c *Cache) warmLinks(ctx context.Context) {
+	w := c.warmer
+	if w.limiter == nil || !c.isHealthy() {
+		return
+	}
+
+	files := w.recentFiles()
+	if w.newWindow > 0 {
+		for _, t := range c.torrents.getAll() {
+			if t.Bad || time.Since(t.AddedOn) > w.newWindow {
+				continue
+			}
+			name := c.GetTorrentFolder(t.Torrent)
+			for filename := range t.Files {
+				files = append(files, warmFile{torrentName: name, filename: filename})
+			}
+		}
+	}
+
+	warmed := 0
+	for _, f := range files {
+		if ctx.Err() != nil || w.interactive.Load() > 0 {
+			break
+		}
+		ct := c.GetTorrentByName(f.torrentName)
+		if ct == nil || ct.Bad {
+			continue
+		}
+		file, ok := ct.Files[f.filename]
+		if !ok || file.Link == "" || !c.needsWarming(file.Link) {
+			continue
+		}
+		if !w.limiter.Allow() {
+			break
+		}
+		if err := c.warmLink(ct, f.filename); err != nil {
+			c.logger.Debug().Err(err).Msgf("Failed to warm link for %s", f.filename)
+			if request.IsTemporary(err) || errors.Is(err, request.TrafficExceededError) {
+				break
+			}
+			continue
+		}
+		warmed++
+	}
+	if warmed > 0 {
+		c.logger.Debug().Msgf("Warmed %d download links", warmed)
+	}
+}
+
+// needsWarming reports whether the download link of a file is missing, invalid, or about to expire
+func (c *Cache) needsWarming(fileLink string) bool {
+	dl, ok := c.downloadLinks.Load(fileLink)
+	if !ok {
+		return true
+	}
+	if _, invalid := c.invalidDownloadLinks.Load(dl.link); invalid {
+		return true
+	}
+	return time.Until(dl.expiresAt) < linkWarmMargin
+}
+
+// warmLink fetches the download link of a file. Unlike a read, it doesn't wait on repairs, a broken
+// torrent is queued behind the interactive ones.
+func (c *Cache) warmLink(ct *CachedTorrent, filename string) error {
+	file := ct.Files[filename]
+	if _, inFlight := c.downloadLinkRequests.Load(file.Link); inFlight {
+		return nil
+	}
+	downloadLink, err := c.client.GetDownloadLink(ct.Torrent, &file)
+	if err != nil {
+		if isLinkBroken(err) {
+			c.markAsBroken(*ct)
+		}
+		return err
+	}
+	if downloadLink == nil {
+		return nil
+	}
+	c.updateDownloadLink(downloadLink)
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -40,11 +41,40 @@ func (c *Cache) RefreshListings(refreshRclone bool) {
 	}
 }
 
+// refreshTorrents picks up torrents added to the debrid. Clients that list torrents newest first
+// are only paged until a cached torrent is reached, deletions are left to refreshTorrentsFull.
 func (c *Cache) refreshTorrents(ctx context.Context) {
-	select {
-	case <-ctx.Done():
+	lister, ok := c.client.(types.IncrementalLister)
+	if !ok {
+		c.refreshTorrentsFull(ctx)
+		return
+	}
+
+	if !c.canRefreshTorrents(ctx) {
+		return
+	}
+
+	if !c.torrentsRefreshMu.TryLock() {
+		return
+	}
+	defer c.torrentsRefreshMu.Unlock()
+
+	cachedTorrents := c.torrents.getIdMaps()
+	newTorrents, err := lister.GetNewTorrents(func(id string) bool {
+		_, exists := cachedTorrents[id]
+		return exists
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to get new torrents")
+		return
+	}
+	c.processNewTorrents(newTorrents)
+}
+
+// refreshTorrentsFull lists every torrent on the debrid, adding new torrents and removing deleted ones
+func (c *Cache) refreshTorrentsFull(ctx context.Context) {
+	if !c.canRefreshTorrents(ctx) {
 		return
-	default:
 	}
 
 	if !c.torrentsRefreshMu.TryLock() {
@@ -88,7 +118,24 @@ func (c *Cache) refreshTorrents(ctx context.Context) {
 			newTorrents = append(newTorrents, t)
 		}
 	}
+	c.processNewTorrents(newTorrents)
+}
 
+func (c *Cache) canRefreshTorrents(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	if !c.isHealthy() {
+		c.logger.Debug().Msg("Skipping torrents refresh, provider is unavailable")
+		return false
+	}
+	return true
+}
+
+func (c *Cache) processNewTorrents(newTorrents []*types.Torrent) {
 	if len(newTorrents) == 0 {
 		return
 	}
@@ -96,9 +143,8 @@ func (c *Cache) refreshTorrents(ctx context.Context) {
 	c.logger.Trace().Msgf("Found %d new torrents", len(newTorrents))
 
 	workChan := make(chan *types.Torrent, min(100, len(newTorrents)))
-	errChan := make(chan error, len(newTorrents))
 	var wg sync.WaitGroup
-	counter := 0
+	var counter atomic.Int64
 
 	for i := 0; i < c.workers; i++ {
 		wg.Add(1)
@@ -107,9 +153,9 @@ func (c *Cache) refreshTorrents(ctx context.Context) {
 			for t := range workChan {
 				if err := c.ProcessTorrent(t); err != nil {
 					c.logger.Error().Err(err).Msgf("Failed to process new torrent %s", t.Id)
-					errChan <- err
+					continue
 				}
-				counter++
+				counter.Add(1)
 			}
 		}()
 	}
@@ -122,7 +168,7 @@ func (c *Cache) refreshTorrents(ctx context.Context) {
 
 	c.listingDebouncer.Call(false)
 
-	c.logger.Debug().Msgf("Processed %d new torrents", counter)
+	c.logger.Debug().Msgf("Processed %d new torrents", counter.Load())
 }
 
 func (c *Cache) refreshRclone() error {
@@ -236,6 +282,11 @@ func (c *Cache) refreshDownloadLinks(ctx context.Context) {
 	default:
 	}
 
+	if !c.isHealthy() {
+		c.logger.Debug().Msg("Skipping download links refresh, provider is unavailable")
+		return
+	}
+
 	if !c.downloadLinksRefreshMu.TryLock() {
 		return
 	}
@@ -247,20 +298,27 @@ func (c *Cache) refreshDownloadLinks(ctx context.Context) {
 		c.logger.Error().Err(err).Msg("Failed to get download links")
 		return
 	}
+	fresh := make(map[string]linkCache, len(downloadLinks))
+	expired := make([]string, 0)
 	for k, v := range downloadLinks {
 		// if link is generated in the last 24 hours, add it to cache
 		timeSince := time.Since(v.Generated)
 		if timeSince < c.autoExpiresLinksAfterDuration {
-			c.downloadLinks.Store(k, linkCache{
+			lc := linkCache{
 				Id:        v.Id,
 				accountId: v.AccountId,
 				link:      v.DownloadLink,
-				expiresAt: v.Generated.Add(c.autoExpiresLinksAfterDuration - timeSince),
-			})
+				expiresAt: v.Generated.Add(c.autoExpiresLinksAfterDuration),
+			}
+			c.downloadLinks.Store(k, lc)
+			fresh[k] = lc
 		} else {
 			c.downloadLinks.Delete(k)
+			expired = append(expired, k)
 		}
 	}
+	c.saveDownloadLinks(fresh)
+	c.deleteSavedLinks(expired...)
 
 	c.logger.Trace().Msgf("Refreshed %d download links", len(downloadLinks))
 
@@ -0,0 +1,363 @@
+package debrid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+// ReinsertFinalAction is what happens to a torrent once its reinsert attempts run out
+type ReinsertFinalAction string
+
+const (
+	ReinsertKeepBad   ReinsertFinalAction = "bad"        // keep it in __bad__
+	ReinsertDelete    ReinsertFinalAction = "delete"     // move it to the trash
+	ReinsertNotifyArr ReinsertFinalAction = "notify_arr" // keep it in __bad__ and have the arr grab another release
+)
+
+// reinsertFailuresKey is the store key the reinsert failures are saved under
+const reinsertFailuresKey = "reinsert_failures"
+
+var ErrReinsertFailureNotFound = errors.New("reinsert failure not found")
+
+// ReinsertFailure tracks a torrent whose reinsert failed. It is retried on the backoff schedule
+// until the attempts run out.
+type ReinsertFailure struct {
+	TorrentID   string    `json:"torrent_id"`
+	Name        string    `json:"name"`
+	InfoHash    string    `json:"info_hash"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	LastAttempt time.Time `json:"last_attempt"`
+	NextAttempt time.Time `json:"next_attempt"`
+	// Final is set once the attempts ran out and the final action ran
+	Final bool `json:"final"`
+}
+
+type reinsertPolicy struct {
+	maxAttempts int
+	backoff     []time.Duration
+	fallback    bool
+	finalAction ReinsertFinalAction
+}
+
+// parseBackoff parses a comma separated list of durations, skipping invalid ones
+func parseBackoff(s string) []time.Duration {
+	backoff := make([]time.Duration, 0)
+	for _, part := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d <= 0 {
+			continue
+		}
+		backoff = append(backoff, d)
+	}
+	if len(backoff) == 0 {
+		backoff = append(backoff, 10*time.Minute)
+	}
+	return backoff
+}
+
+// delay returns the wait before the next attempt, the last delay repeats
+func (p reinsertPolicy) delay(attempts int) time.Duration {
+	return p.backoff[min(max(attempts-1, 0), len(p.backoff)-1)]
+}
+
+func (c *Cache) loadReinsertFailures() {
+	data, err := c.store.GetMeta(reinsertFailuresKey)
+	if err != nil || data == nil {
+		return
+	}
+	failures := make(map[string]*ReinsertFailure)
+	if err := json.Unmarshal(data, &failures); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to decode reinsert failures")
+		return
+	}
+	c.reinsertMu.Lock()
+	c.reinsertFailures = failures
+	c.reinsertMu.Unlock()
+}
+
+// saveReinsertFailures persists the reinsert failures. Must be called with reinsertMu held.
+func (c *Cache) saveReinsertFailures() {
+	data, err := json.Marshal(c.reinsertFailures)
+	if err != nil {
+		return
+	}
+	if err := c.store.PutMeta(reinsertFailuresKey, data); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to save reinsert failures")
+	}
+}
+
+// canReinsert returns an error if the torrent gave up reinserting, or waits for its next attempt
+func (c *Cache) canReinsert(torrentId string) error {
+	c.reinsertMu.Lock()
+	defer c.reinsertMu.Unlock()
+	f, ok := c.reinsertFailures[torrentId]
+	if !ok {
+		return nil
+	}
+	if f.Final {
+		return fmt.Errorf("can't retry re-insert for %s, gave up after %d attempts", torrentId, f.Attempts)
+	}
+	if time.Now().Before(f.NextAttempt) {
+		return fmt.Errorf("can't retry re-insert for %s before %s", torrentId, f.NextAttempt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// GetReinsertFailures returns the torrents whose reinsert failed, most recent first
+func (c *Cache) GetReinsertFailures() []ReinsertFailure {
+	c.reinsertMu.Lock()
+	failures := make([]ReinsertFailure, 0, len(c.reinsertFailures))
+	for _, f := range c.reinsertFailures {
+		failures = append(failures, *f)
+	}
+	c.reinsertMu.Unlock()
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].LastAttempt.After(failures[j].LastAttempt)
+	})
+	return failures
+}
+
+// ResetReinsertFailure forgets the failed reinserts of a torrent and takes it out of __bad__
+func (c *Cache) ResetReinsertFailure(torrentId string) error {
+	c.reinsertMu.Lock()
+	_, found := c.reinsertFailures[torrentId]
+	if found {
+		delete(c.reinsertFailures, torrentId)
+		c.saveReinsertFailures()
+	}
+	c.reinsertMu.Unlock()
+
+	if torrent, ok := c.torrents.getByID(torrentId); ok && torrent.Bad {
+		found = true
+		torrent.Bad = false
+		c.setTorrent(torrent, func(t CachedTorrent) {
+			c.RefreshListings(false)
+		})
+	}
+	if !found {
+		return ErrReinsertFailureNotFound
+	}
+	return nil
+}
+
+// markAsFailedToReinsert moves the torrent to __bad__ and schedules its next attempt. Once the attempts
+// run out the other debrids are tried, if enabled, then the final action runs.
+func (c *Cache) markAsFailedToReinsert(torrentId string, reason error) {
+	torrent, ok := c.torrents.getByID(torrentId)
+	if !ok {
+		return
+	}
+
+	c.reinsertMu.Lock()
+	f, ok := c.reinsertFailures[torrentId]
+	if !ok {
+		f = &ReinsertFailure{TorrentID: torrentId, Name: torrent.Name, InfoHash: torrent.InfoHash}
+		c.reinsertFailures[torrentId] = f
+	}
+	f.Attempts++
+	f.LastAttempt = time.Now()
+	if reason != nil {
+		f.LastError = reason.Error()
+	}
+	final := f.Attempts >= c.reinsertPolicy.maxAttempts
+	if final {
+		f.Final = true
+		f.NextAttempt = time.Time{}
+	} else {
+		f.NextAttempt = f.LastAttempt.Add(c.reinsertPolicy.delay(f.Attempts))
+	}
+	attempts, next := f.Attempts, f.NextAttempt
+	c.saveReinsertFailures()
+	c.reinsertMu.Unlock()
+
+	torrent.Bad = true
+	c.setTorrent(torrent, func(t CachedTorrent) {
+		c.RefreshListings(false)
+	})
+
+	if !final {
+		c.logger.Info().Str("torrentId", torrentId).Msgf("Reinsert attempt %d/%d failed for %s, retrying at %s",
+			attempts, c.reinsertPolicy.maxAttempts, torrent.Name, next.Format(time.RFC3339))
+		return
+	}
+	c.logger.Warn().Str("torrentId", torrentId).Msgf("Giving up reinserting %s after %d attempts", torrent.Name, attempts)
+	c.giveUpReinsert(torrent)
+}
+
+func (c *Cache) markAsSuccessfullyReinserted(torrentId string) {
+	c.reinsertMu.Lock()
+	if _, ok := c.reinsertFailures[torrentId]; !ok {
+		c.reinsertMu.Unlock()
+		return
+	}
+	delete(c.reinsertFailures, torrentId)
+	c.saveReinsertFailures()
+	c.reinsertMu.Unlock()
+
+	if torrent, ok := c.torrents.getByID(torrentId); ok {
+		torrent.Bad = false
+		c.setTorrent(torrent, func(torrent CachedTorrent) {
+			c.RefreshListings(false)
+		})
+	}
+}
+
+// giveUpReinsert tries the other debrids, then runs the final action
+func (c *Cache) giveUpReinsert(torrent CachedTorrent) {
+	if c.reinsertPolicy.fallback {
+		if err := c.moveToPeer(torrent); err == nil {
+			return
+		}
+	}
+
+	switch c.reinsertPolicy.finalAction {
+	case ReinsertDelete:
+		c.forgetReinsertFailure(torrent.Id)
+		if err := c.DeleteTorrent(torrent.Id); err != nil {
+			c.logger.Error().Err(err).Str("torrentId", torrent.Id).Msg("Failed to delete torrent")
+		}
+	case ReinsertNotifyArr:
+		if torrent.Arr == nil {
+			c.logger.Warn().Str("torrentId", torrent.Id).Msgf("No arr to notify for %s", torrent.Name)
+			return
+		}
+		if err := torrent.Arr.MarkAsFailed(torrent.InfoHash); err != nil {
+			c.logger.Error().Err(err).Str("torrentId", torrent.Id).Msgf("Failed to notify %s", torrent.Arr.Name)
+			return
+		}
+		c.logger.Info().Str("torrentId", torrent.Id).Msgf("Notified %s that %s failed", torrent.Arr.Name, torrent.Name)
+	}
+}
+
+// moveToPeer reinserts the torrent on the first other debrid that has it, under the same folder name
+// where the debrid's folder naming allows, then trashes it here.
+func (c *Cache) moveToPeer(torrent CachedTorrent) error {
+	for _, peer := range c.peers {
+		select {
+		case <-peer.ready:
+		default:
+			continue
+		}
+		if !peer.isHealthy() {
+			continue
+		}
+		moved, err := peer.resubmit(torrent.Torrent)
+		if err != nil {
+			c.logger.Debug().Err(err).Str("torrentId", torrent.Id).Msgf("Failed to reinsert on %s", peer.client.GetName())
+			continue
+		}
+		if moved == nil {
+			// Added but filtered out of the peer's cache
+			continue
+		}
+		m := TorrentMove{
+			InfoHash: torrent.InfoHash,
+			From:     c.client.GetName(),
+			To:       peer.client.GetName(),
+			OldID:    torrent.Id,
+			NewID:    moved.Id,
+			OldPath:  filepath.Join(c.client.GetMountPath(), c.GetTorrentFolder(torrent.Torrent)),
+			NewPath:  filepath.Join(peer.client.GetMountPath(), peer.GetTorrentFolder(moved.Torrent)),
+		}
+		c.logger.Info().Str("torrentId", torrent.Id).Msgf("Moved %s to %s as %s", torrent.Name, m.To, m.NewID)
+		c.forgetReinsertFailure(torrent.Id)
+		if err := c.DeleteTorrent(torrent.Id); err != nil {
+			c.logger.Error().Err(err).Str("torrentId", torrent.Id).Msg("Failed to delete torrent")
+		}
+		if c.onMoved != nil {
+			c.onMoved(m)
+		}
+		return nil
+	}
+	c.logger.Debug().Str("torrentId", torrent.Id).Msg("No other debrid could reinsert the torrent")
+	return fmt.Errorf("no other debrid has %s", torrent.InfoHash)
+}
+
+func (c *Cache) forgetReinsertFailure(torrentId string) {
+	c.reinsertMu.Lock()
+	defer c.reinsertMu.Unlock()
+	if _, ok := c.reinsertFailures[torrentId]; ok {
+		delete(c.reinsertFailures, torrentId)
+		c.saveReinsertFailures()
+	}
+}
+
+// retryReinserts queues the reinserts whose backoff elapsed and forgets the torrents that are gone
+func (c *Cache) retryReinserts() {
+	if !c.isHealthy() {
+		return
+	}
+	now := time.Now()
+	due := make([]string, 0)
+	c.reinsertMu.Lock()
+	changed := false
+	for id, f := range c.reinsertFailures {
+		if _, ok := c.torrents.getByID(id); !ok {
+			delete(c.reinsertFailures, id)
+			changed = true
+			continue
+		}
+		if !f.Final && !now.Before(f.NextAttempt) {
+			due = append(due, id)
+		}
+	}
+	if changed {
+		c.saveReinsertFailures()
+	}
+	c.reinsertMu.Unlock()
+
+	for _, id := range due {
+		c.queueRepair(RepairRequest{
+			Type:      RepairTypeReinsert,
+			TorrentID: id,
+			Priority:  RepairPriorityScan,
+		})
+	}
+}
+
+// resubmit adds a torrent again from its infohash, served under the same folder name
+func (c *Cache) resubmit(old *types.Torrent) (*CachedTorrent, error) {
+	if old.InfoHash == "" {
+		return nil, fmt.Errorf("torrent %s has no infohash", old.Id)
+	}
+	newTorrent := &types.Torrent{
+		Name:     old.Name,
+		Magnet:   utils.ConstructMagnet(old.InfoHash, old.Name),
+		InfoHash: old.InfoHash,
+		Size:     old.Size,
+		Files:    make(map[string]types.File),
+		Arr:      old.Arr,
+	}
+	newTorrent, err := c.client.SubmitMagnet(newTorrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit magnet: %w", err)
+	}
+	if newTorrent == nil || newTorrent.Id == "" {
+		return nil, fmt.Errorf("failed to submit magnet: empty torrent")
+	}
+	newTorrent.DownloadUncached = false
+	newTorrent, err = c.client.CheckStatus(newTorrent, true)
+	if err != nil {
+		if newTorrent != nil && newTorrent.Id != "" {
+			_ = c.client.DeleteTorrent(newTorrent.Id)
+		}
+		return nil, err
+	}
+
+	// Keep the folder the torrent was served from
+	newTorrent.Filename = old.Filename
+	newTorrent.OriginalFilename = old.OriginalFilename
+	if err := c.AddTorrent(newTorrent); err != nil {
+		return nil, err
+	}
+	return c.GetTorrent(newTorrent.Id), nil
+}
@@ -1,11 +1,11 @@
 package debrid
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/store"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"sync"
 	"time"
@@ -34,31 +34,6 @@ func (r *reInsertRequest) Wait() (*CachedTorrent, error) {
 	return r.result, r.err
 }
 
-func (c *Cache) markAsFailedToReinsert(torrentId string) {
-	c.failedToReinsert.Store(torrentId, struct{}{})
-
-	// Remove the torrent from the directory if it has failed to reinsert, max retries are hardcoded to 5
-	if torrent, ok := c.torrents.getByID(torrentId); ok {
-		torrent.Bad = true
-		c.setTorrent(torrent, func(t CachedTorrent) {
-			c.RefreshListings(false)
-		})
-	}
-}
-
-func (c *Cache) markAsSuccessfullyReinserted(torrentId string) {
-	if _, ok := c.failedToReinsert.Load(torrentId); !ok {
-		return
-	}
-	c.failedToReinsert.Delete(torrentId)
-	if torrent, ok := c.torrents.getByID(torrentId); ok {
-		torrent.Bad = false
-		c.setTorrent(torrent, func(torrent CachedTorrent) {
-			c.RefreshListings(false)
-		})
-	}
-}
-
 func (c *Cache) IsTorrentBroken(t *CachedTorrent, filenames []string) bool {
 	// Check torrent files
 
@@ -103,7 +78,7 @@ func (c *Cache) IsTorrentBroken(t *CachedTorrent, filenames []string) bool {
 		} else {
 			// Check if file.Link not in the downloadLink Cache
 			if err := c.client.CheckLink(f.Link); err != nil {
-				if errors.Is(err, request.HosterUnavailableError) {
+				if isLinkBroken(err) {
 					isBroken = true
 					break
 				}
@@ -113,7 +88,7 @@ func (c *Cache) IsTorrentBroken(t *CachedTorrent, filenames []string) bool {
 	// Try to reinsert the torrent if it's broken
 	if isBroken && t.Torrent != nil {
 		// Check if the torrent is already in progress
-		if _, err := c.reInsertTorrent(t); err != nil {
+		if _, err := c.repairAndWait(t, RepairPriorityRepair); err != nil {
 			c.logger.Error().Err(err).Str("torrentId", t.Torrent.Id).Msg("Failed to reinsert torrent")
 			return true
 		}
@@ -123,54 +98,12 @@ func (c *Cache) IsTorrentBroken(t *CachedTorrent, filenames []string) bool {
 	return isBroken
 }
 
-func (c *Cache) repairWorker(ctx context.Context) {
-	// This watches a channel for torrents to repair and can be cancelled via context
-	for {
-		select {
-		case <-ctx.Done():
-			return
-
-		case req, ok := <-c.repairChan:
-			// Channel was closed
-			if !ok {
-				c.logger.Debug().Msg("Repair channel closed, shutting down worker")
-				return
-			}
-
-			torrentId := req.TorrentID
-			c.logger.Debug().Str("torrentId", req.TorrentID).Msg("Received repair request")
-
-			// Get the torrent from the cache
-			cachedTorrent := c.GetTorrent(torrentId)
-			if cachedTorrent == nil {
-				c.logger.Warn().Str("torrentId", torrentId).Msg("Torrent not found in cache")
-				continue
-			}
-
-			switch req.Type {
-			case RepairTypeReinsert:
-				c.logger.Debug().Str("torrentId", torrentId).Msg("Reinserting torrent")
-				if _, err := c.reInsertTorrent(cachedTorrent); err != nil {
-					c.logger.Error().Err(err).Str("torrentId", cachedTorrent.Id).Msg("Failed to reinsert torrent")
-					continue
-				}
-			case RepairTypeDelete:
-				c.logger.Debug().Str("torrentId", torrentId).Msg("Deleting torrent")
-				if err := c.DeleteTorrent(torrentId); err != nil {
-					c.logger.Error().Err(err).Str("torrentId", torrentId).Msg("Failed to delete torrent")
-					continue
-				}
-			}
-		}
-	}
-}
-
-func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
+func (c *Cache) reInsertTorrent(ct *CachedTorrent) (result *CachedTorrent, err error) {
 	// Check if Magnet is not empty, if empty, reconstruct the magnet
 	torrent := ct.Torrent
 	oldID := torrent.Id // Store the old ID
-	if _, ok := c.failedToReinsert.Load(oldID); ok {
-		return ct, fmt.Errorf("can't retry re-insert for %s", torrent.Id)
+	if err := c.canReinsert(oldID); err != nil {
+		return ct, err
 	}
 	if reqI, inFlight := c.repairRequest.Load(oldID); inFlight {
 		req := reqI.(*reInsertRequest)
@@ -180,9 +113,10 @@ func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
 	req := newReInsertRequest()
 	c.repairRequest.Store(oldID, req)
 
-	// Make sure we clean up even if there's a panic
+	// Make sure we clean up and release the waiters even if there's a panic
 	defer func() {
 		c.repairRequest.Delete(oldID)
+		req.Complete(result, err)
 	}()
 
 	// Submit the magnet to the debrid service
@@ -194,18 +128,21 @@ func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
 		Files:    make(map[string]types.File),
 		Arr:      torrent.Arr,
 	}
-	var err error
 	newTorrent, err = c.client.SubmitMagnet(newTorrent)
 	if err != nil {
-		c.markAsFailedToReinsert(oldID)
+		if !request.IsTemporary(err) {
+			// Rate limits are retried by the repair queue
+			c.markAsFailedToReinsert(oldID, err)
+		}
 		// Remove the old torrent from the cache and debrid service
 		return ct, fmt.Errorf("failed to submit magnet: %w", err)
 	}
 
 	// Check if the torrent was submitted
 	if newTorrent == nil || newTorrent.Id == "" {
-		c.markAsFailedToReinsert(oldID)
-		return ct, fmt.Errorf("failed to submit magnet: empty torrent")
+		err = fmt.Errorf("failed to submit magnet: empty torrent")
+		c.markAsFailedToReinsert(oldID, err)
+		return ct, err
 	}
 	newTorrent.DownloadUncached = false // Set to false, avoid re-downloading
 	newTorrent, err = c.client.CheckStatus(newTorrent, true)
@@ -214,7 +151,9 @@ func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
 			// Delete the torrent if it was not downloaded
 			_ = c.client.DeleteTorrent(newTorrent.Id)
 		}
-		c.markAsFailedToReinsert(oldID)
+		if !request.IsTemporary(err) {
+			c.markAsFailedToReinsert(oldID, err)
+		}
 		return ct, err
 	}
 
@@ -225,8 +164,9 @@ func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
 	}
 	for _, f := range newTorrent.Files {
 		if f.Link == "" {
-			c.markAsFailedToReinsert(oldID)
-			return ct, fmt.Errorf("failed to reinsert torrent: empty link")
+			err = fmt.Errorf("failed to reinsert torrent: empty link")
+			c.markAsFailedToReinsert(oldID, err)
+			return ct, err
 		}
 	}
 	// Set torrent to newTorrent
@@ -248,7 +188,6 @@ func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
 		}
 	}
 
-	req.Complete(ct, err)
 	c.markAsSuccessfullyReinserted(oldID)
 
 	c.logger.Debug().Str("torrentId", torrent.Id).Msg("Torrent successfully reinserted")
@@ -256,7 +195,18 @@ func (c *Cache) reInsertTorrent(ct *CachedTorrent) (*CachedTorrent, error) {
 	return ct, nil
 }
 
+// isLinkBroken reports whether a CheckLink error means the file is gone, as opposed to a temporary failure
+func isLinkBroken(err error) bool {
+	return errors.Is(err, request.HosterUnavailableError) ||
+		errors.Is(err, request.ErrLinkBroken) ||
+		errors.Is(err, request.InfringingFileError) ||
+		errors.Is(err, request.TorrentNotFoundError)
+}
+
 func (c *Cache) resetInvalidLinks() {
 	c.invalidDownloadLinks = sync.Map{}
+	if err := c.store.ClearLinks(store.InvalidLinks); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to clear invalid download links")
+	}
 	c.client.ResetActiveDownloadKeys() // Reset the active download keys
 }
@@ -0,0 +1,312 @@
+package debrid
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+// Repair priorities, higher runs first
+const (
+	RepairPriorityScan        = 0  // found by the health scan
+	RepairPriorityRepair      = 5  // found by a repair job
+	RepairPriorityInteractive = 10 // a user is waiting on the file
+)
+
+const (
+	// repairQueueKey is the store key pending repairs are saved under
+	repairQueueKey = "repair_queue"
+	// repairMaxAttempts and repairBackoff bound the retries of repairs failing with a temporary error
+	repairMaxAttempts = 5
+	repairBackoff     = 30 * time.Second
+	repairMaxBackoff  = 30 * time.Minute
+)
+
+var errTorrentNotCached = errors.New("torrent not found in cache")
+
+type repairResult struct {
+	torrent *CachedTorrent
+	err     error
+}
+
+type queuedRepair struct {
+	req      RepairRequest
+	attempts int
+	seq      uint64
+	// index is the position in the heap, -1 while running or waiting for a retry
+	index   int
+	delayed bool
+	waiters []chan repairResult
+}
+
+type repairHeap []*queuedRepair
+
+func (h repairHeap) Len() int { return len(h) }
+func (h repairHeap) Less(i, j int) bool {
+	if h[i].req.Priority != h[j].req.Priority {
+		return h[i].req.Priority > h[j].req.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h repairHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *repairHeap) Push(x any) {
+	item := x.(*queuedRepair)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *repairHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// repairQueue orders repairs by priority, then by arrival. A torrent is only queued once, queuing it
+// again raises its priority.
+type repairQueue struct {
+	mu     sync.Mutex
+	items  repairHeap
+	byID   map[string]*queuedRepair // queued, running or waiting for a retry
+	seq    uint64
+	notify chan struct{}
+}
+
+func newRepairQueue() *repairQueue {
+	return &repairQueue{
+		byID:   make(map[string]*queuedRepair),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (q *repairQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *repairQueue) push(req RepairRequest, attempts int, waiter chan repairResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if item, ok := q.byID[req.TorrentID]; ok {
+		if waiter != nil {
+			item.waiters = append(item.waiters, waiter)
+			if item.delayed {
+				// Someone is waiting, don't hold the retry back
+				item.delayed = false
+				heap.Push(&q.items, item)
+				q.signal()
+			}
+		}
+		if req.Priority > item.req.Priority {
+			item.req.Priority = req.Priority
+			if item.index >= 0 {
+				heap.Fix(&q.items, item.index)
+			}
+		}
+		return
+	}
+	q.seq++
+	item := &queuedRepair{req: req, attempts: attempts, seq: q.seq}
+	if waiter != nil {
+		item.waiters = append(item.waiters, waiter)
+	}
+	q.byID[req.TorrentID] = item
+	heap.Push(&q.items, item)
+	q.signal()
+}
+
+// pop blocks until a repair is ready or ctx is done
+func (q *repairQueue) pop(ctx context.Context) *queuedRepair {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			item := heap.Pop(&q.items).(*queuedRepair)
+			if len(q.items) > 0 {
+				// Wake another worker for the rest
+				q.signal()
+			}
+			q.mu.Unlock()
+			return item
+		}
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.notify:
+		}
+	}
+}
+
+// done hands the result to the waiters. A repair with a retry delay is queued again once it elapses.
+func (q *repairQueue) done(item *queuedRepair, result repairResult, retryAfter time.Duration) {
+	q.mu.Lock()
+	waiters := item.waiters
+	item.waiters = nil
+	if retryAfter > 0 {
+		item.attempts++
+		item.delayed = true
+		time.AfterFunc(retryAfter, func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if q.byID[item.req.TorrentID] != item || !item.delayed {
+				return
+			}
+			item.delayed = false
+			heap.Push(&q.items, item)
+			q.signal()
+		})
+	} else {
+		delete(q.byID, item.req.TorrentID)
+	}
+	q.mu.Unlock()
+
+	for _, w := range waiters {
+		w <- result
+	}
+}
+
+// cancel fails the repairs someone waits on and empties the queue
+func (q *repairQueue) cancel(err error) {
+	q.mu.Lock()
+	var waiters []chan repairResult
+	for _, item := range q.byID {
+		waiters = append(waiters, item.waiters...)
+		item.waiters = nil
+	}
+	q.byID = make(map[string]*queuedRepair)
+	q.items = nil
+	q.mu.Unlock()
+	for _, w := range waiters {
+		w <- repairResult{err: err}
+	}
+}
+
+type pendingRepair struct {
+	Request  RepairRequest `json:"request"`
+	Attempts int           `json:"attempts"`
+}
+
+func (q *repairQueue) pending() []pendingRepair {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := make([]pendingRepair, 0, len(q.byID))
+	for _, item := range q.byID {
+		pending = append(pending, pendingRepair{Request: item.req, Attempts: item.attempts})
+	}
+	return pending
+}
+
+// queueRepair adds a repair to the queue without waiting for it
+func (c *Cache) queueRepair(req RepairRequest) {
+	c.repairs.push(req, 0, nil)
+	c.saveRepairQueue()
+}
+
+// repairAndWait queues a reinsert and waits for its outcome
+func (c *Cache) repairAndWait(ct *CachedTorrent, priority int) (*CachedTorrent, error) {
+	if err := c.canReinsert(ct.Id); err != nil {
+		return ct, err
+	}
+	waiter := make(chan repairResult, 1)
+	c.repairs.push(RepairRequest{
+		Type:      RepairTypeReinsert,
+		TorrentID: ct.Id,
+		Priority:  priority,
+	}, 0, waiter)
+	c.saveRepairQueue()
+	result := <-waiter
+	if result.torrent == nil {
+		return ct, result.err
+	}
+	return result.torrent, result.err
+}
+
+func (c *Cache) saveRepairQueue() {
+	data, err := json.Marshal(c.repairs.pending())
+	if err != nil {
+		return
+	}
+	if err := c.store.PutMeta(repairQueueKey, data); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to save repair queue")
+	}
+}
+
+// loadRepairQueue queues the repairs left pending by the last run
+func (c *Cache) loadRepairQueue() {
+	data, err := c.store.GetMeta(repairQueueKey)
+	if err != nil || data == nil {
+		return
+	}
+	var pending []pendingRepair
+	if err := json.Unmarshal(data, &pending); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to decode repair queue")
+		return
+	}
+	for _, p := range pending {
+		c.repairs.push(p.Request, p.Attempts, nil)
+	}
+	if len(pending) > 0 {
+		c.logger.Info().Msgf("Resuming %d pending repairs", len(pending))
+	}
+}
+
+func (c *Cache) repairWorker(ctx context.Context) {
+	// This watches the queue for torrents to repair and can be cancelled via context
+	for {
+		item := c.repairs.pop(ctx)
+		if item == nil {
+			return
+		}
+		req := item.req
+		c.logger.Debug().Str("torrentId", req.TorrentID).Msg("Received repair request")
+
+		result := c.repair(req)
+		var retryAfter time.Duration
+		if result.err != nil && request.IsTemporary(result.err) && item.attempts+1 < repairMaxAttempts {
+			retryAfter = min(repairBackoff<<item.attempts, repairMaxBackoff)
+			c.logger.Debug().Err(result.err).Str("torrentId", req.TorrentID).Msgf("Retrying repair in %s", retryAfter)
+		}
+		c.repairs.done(item, result, retryAfter)
+		c.saveRepairQueue()
+	}
+}
+
+func (c *Cache) repair(req RepairRequest) repairResult {
+	torrentId := req.TorrentID
+	// Get the torrent from the cache
+	cachedTorrent := c.GetTorrent(torrentId)
+	if cachedTorrent == nil {
+		c.logger.Warn().Str("torrentId", torrentId).Msg("Torrent not found in cache")
+		return repairResult{err: errTorrentNotCached}
+	}
+
+	switch req.Type {
+	case RepairTypeReinsert:
+		c.logger.Debug().Str("torrentId", torrentId).Msg("Reinserting torrent")
+		newCt, err := c.reInsertTorrent(cachedTorrent)
+		if err != nil {
+			c.logger.Error().Err(err).Str("torrentId", cachedTorrent.Id).Msg("Failed to reinsert torrent")
+		}
+		return repairResult{torrent: newCt, err: err}
+	case RepairTypeDelete:
+		c.logger.Debug().Str("torrentId", torrentId).Msg("Deleting torrent")
+		if err := c.DeleteTorrent(torrentId); err != nil {
+			c.logger.Error().Err(err).Str("torrentId", torrentId).Msg("Failed to delete torrent")
+			return repairResult{err: err}
+		}
+	}
+	return repairResult{}
+}
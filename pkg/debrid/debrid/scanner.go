@@ -0,0 +1,193 @@
+package debrid
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+// scanStateKey is the store key the health scan state is saved under
+const scanStateKey = "health_scan"
+
+// ScanFinding is a torrent the health scan found broken
+type ScanFinding struct {
+	TorrentID string    `json:"torrent_id"`
+	Name      string    `json:"name"`
+	File      string    `json:"file"`
+	Reason    string    `json:"reason"`
+	FoundAt   time.Time `json:"found_at"`
+}
+
+// ScanState is the progress of the health scan. It is saved after every batch so a pass resumes after a restart.
+type ScanState struct {
+	Running    bool          `json:"running"`
+	Cursor     string        `json:"cursor"` // ID of the last torrent checked, torrents are checked in ID order
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Total      int           `json:"total"`
+	Checked    int           `json:"checked"`
+	Broken     []ScanFinding `json:"broken"`
+}
+
+type healthScanner struct {
+	mu        sync.Mutex
+	state     ScanState
+	interval  time.Duration
+	batchSize int
+}
+
+func (c *Cache) loadScanState() {
+	data, err := c.store.GetMeta(scanStateKey)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to load health scan state")
+		return
+	}
+	if data == nil {
+		return
+	}
+	var state ScanState
+	if err := json.Unmarshal(data, &state); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to decode health scan state")
+		return
+	}
+	c.scanner.mu.Lock()
+	c.scanner.state = state
+	c.scanner.mu.Unlock()
+}
+
+// saveScanState persists the scan state. Must be called with the scanner lock held.
+func (c *Cache) saveScanState() {
+	data, err := json.Marshal(c.scanner.state)
+	if err != nil {
+		return
+	}
+	if err := c.store.PutMeta(scanStateKey, data); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to save health scan state")
+	}
+}
+
+// GetScanState returns the progress and findings of the health scan
+func (c *Cache) GetScanState() ScanState {
+	c.scanner.mu.Lock()
+	defer c.scanner.mu.Unlock()
+	state := c.scanner.state
+	state.Broken = append([]ScanFinding(nil), state.Broken...)
+	return state
+}
+
+// scanHealth checks the next batch of torrents. A pass starts once the interval has elapsed since the
+// last one started, and walks the torrents a batch at a time so the provider isn't flooded.
+func (c *Cache) scanHealth(ctx context.Context) {
+	if c.scanner.interval <= 0 || !c.isHealthy() {
+		return
+	}
+	if !c.scanner.mu.TryLock() {
+		return
+	}
+	defer c.scanner.mu.Unlock()
+
+	state := &c.scanner.state
+	if !state.Running {
+		if !state.StartedAt.IsZero() && time.Since(state.StartedAt) < c.scanner.interval {
+			return
+		}
+		*state = ScanState{Running: true, StartedAt: time.Now()}
+		c.logger.Debug().Msg("Starting health scan")
+	}
+
+	torrents := c.torrents.getAll()
+	ids := make([]string, 0, len(torrents))
+	for id := range torrents {
+		if id > state.Cursor {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	state.Total = state.Checked + len(ids)
+
+	for i, id := range ids {
+		if i >= c.scanner.batchSize {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			c.saveScanState()
+			return
+		default:
+		}
+		t := torrents[id]
+		if t.Bad {
+			state.Cursor = id
+			state.Checked++
+			continue
+		}
+		file, err := c.checkSample(t)
+		if request.IsTemporary(err) {
+			// Rate limited, pick up from here on the next run
+			c.logger.Debug().Err(err).Msg("Pausing health scan")
+			break
+		}
+		state.Cursor = id
+		state.Checked++
+		if err == nil {
+			continue
+		}
+		c.logger.Info().Err(err).Str("torrentId", id).Msgf("Health scan found a broken torrent: %s", t.Name)
+		state.Broken = append(state.Broken, ScanFinding{
+			TorrentID: id,
+			Name:      t.Name,
+			File:      file,
+			Reason:    request.ErrorCode(err),
+			FoundAt:   time.Now(),
+		})
+		c.markAsBroken(t)
+	}
+
+	if state.Checked >= state.Total {
+		state.Running = false
+		state.Cursor = ""
+		state.FinishedAt = time.Now()
+		c.logger.Info().Msgf("Health scan finished: %d torrents checked, %d broken", state.Checked, len(state.Broken))
+	}
+	c.saveScanState()
+}
+
+// checkSample checks the link of the largest file of a torrent. Returns the file checked and the error
+// if the link is broken, or a temporary error.
+func (c *Cache) checkSample(t CachedTorrent) (string, error) {
+	var sample *types.File
+	for _, f := range t.Files {
+		if sample == nil || f.Size > sample.Size {
+			sample = &f
+		}
+	}
+	if sample == nil {
+		return "", nil
+	}
+	if sample.Link == "" {
+		return sample.Name, request.ErrLinkBroken
+	}
+	err := c.client.CheckLink(sample.Link)
+	if err != nil && (isLinkBroken(err) || request.IsTemporary(err)) {
+		return sample.Name, err
+	}
+	return sample.Name, nil
+}
+
+// markAsBroken moves a torrent to __bad__ and queues a reinsert
+func (c *Cache) markAsBroken(t CachedTorrent) {
+	t.Bad = true
+	c.setTorrent(t, func(CachedTorrent) {
+		c.listingDebouncer.Call(false)
+	})
+	c.queueRepair(RepairRequest{
+		Type:      RepairTypeReinsert,
+		TorrentID: t.Id,
+		Priority:  RepairPriorityScan,
+	})
+}
@@ -0,0 +1,200 @@
+package debrid
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+const (
+	selectionReasonCached   = "cached"
+	selectionReasonPriority = "priority"
+)
+
+// candidate is a debrid client that can receive a torrent, along with why it was picked
+type candidate struct {
+	name   string
+	client types.Client
+	cached bool
+	reason string
+}
+
+// clientProfile holds the routing settings of a single debrid
+type clientProfile struct {
+	name        string
+	priority    int
+	weight      int
+	allowedArrs []string
+	deniedArrs  []string
+}
+
+// accepts reports whether the debrid takes torrents from the given arr
+func (p clientProfile) accepts(arrName string) bool {
+	if slices.Contains(p.deniedArrs, arrName) {
+		return false
+	}
+	if len(p.allowedArrs) > 0 && !slices.Contains(p.allowedArrs, arrName) {
+		return false
+	}
+	return true
+}
+
+// selector decides the order in which debrids are tried
+type selector struct {
+	strategy string
+	profiles []clientProfile // sorted by priority, then by configuration order
+
+	mu             sync.Mutex
+	currentWeights map[string]int
+	lastFailed     map[string]time.Time
+}
+
+func newSelector(strategy string, debrids []config.Debrid) *selector {
+	profiles := make([]clientProfile, 0, len(debrids))
+	for _, dc := range debrids {
+		profiles = append(profiles, clientProfile{
+			name:        dc.Name,
+			priority:    dc.Priority,
+			weight:      max(dc.Weight, 1),
+			allowedArrs: dc.AllowedArrs,
+			deniedArrs:  dc.DeniedArrs,
+		})
+	}
+	sort.SliceStable(profiles, func(i, j int) bool {
+		return profiles[i].priority < profiles[j].priority
+	})
+	return &selector{
+		strategy:       strategy,
+		profiles:       profiles,
+		currentWeights: make(map[string]int),
+		lastFailed:     make(map[string]time.Time),
+	}
+}
+
+// order returns the names of the debrids that accept torrents from arrName, in the order they should be tried
+func (s *selector) order(arrName string) []string {
+	profiles := make([]clientProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		if p.accepts(arrName) {
+			profiles = append(profiles, p)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.strategy {
+	case config.DebridSelectionWeighted:
+		profiles = s.weighted(profiles)
+	case config.DebridSelectionLeastFailed:
+		sort.SliceStable(profiles, func(i, j int) bool {
+			return s.lastFailed[profiles[i].name].Before(s.lastFailed[profiles[j].name])
+		})
+	}
+
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// weighted moves the next debrid picked by smooth weighted round-robin to the front.
+// The rest keep their priority order and serve as fallbacks.
+func (s *selector) weighted(profiles []clientProfile) []clientProfile {
+	if len(profiles) < 2 {
+		return profiles
+	}
+	total := 0
+	best := -1
+	for i, p := range profiles {
+		s.currentWeights[p.name] += p.weight
+		total += p.weight
+		if best == -1 || s.currentWeights[p.name] > s.currentWeights[profiles[best].name] {
+			best = i
+		}
+	}
+	s.currentWeights[profiles[best].name] -= total
+
+	result := make([]clientProfile, 0, len(profiles))
+	result = append(result, profiles[best])
+	result = append(result, profiles[:best]...)
+	return append(result, profiles[best+1:]...)
+}
+
+func (s *selector) markFailed(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFailed[name] = time.Now()
+}
+
+// orderedClients returns the clients that accept torrents from arrName, in the order they should be tried
+func (d *Engine) orderedClients(arrName string) []candidate {
+	d.clientsMu.Lock()
+	defer d.clientsMu.Unlock()
+	result := make([]candidate, 0, len(d.Clients))
+	for _, name := range d.selector.order(arrName) {
+		if cb, ok := d.health[name]; ok && cb.IsOpen() {
+			// Provider is down, skip it until it can be probed again
+			continue
+		}
+		if client, ok := d.Clients[name]; ok {
+			result = append(result, candidate{name: name, client: client})
+		}
+	}
+	return result
+}
+
+// checkAvailability asks every client at the same time whether the hash is cached
+func (d *Engine) checkAvailability(candidates []candidate, infohash string) []candidate {
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(c *candidate) {
+			defer wg.Done()
+			c.cached = d.availability.lookup(c.name, c.client, []string{infohash})[infohash]
+		}(&candidates[i])
+	}
+	wg.Wait()
+	return candidates
+}
+
+// selectClients orders the clients for a torrent.
+// Providers that already have the hash cached come first, the rest follow in the configured order.
+func (d *Engine) selectClients(infohash, arrName string) []candidate {
+	candidates := d.checkAvailability(d.orderedClients(arrName), infohash)
+
+	selected := make([]candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.cached {
+			c.reason = selectionReasonCached
+			selected = append(selected, c)
+		}
+	}
+	for _, c := range candidates {
+		if !c.cached {
+			c.reason = selectionReasonPriority
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// isHashCached looks up a hash in an IsAvailable result, ok is false when the provider didn't answer for it.
+// Providers don't agree on the case of the returned keys, so the lookup is case-insensitive.
+func isHashCached(result map[string]bool, infohash string) (cached, ok bool) {
+	if cached, ok := result[infohash]; ok {
+		return cached, true
+	}
+	for h, cached := range result {
+		if strings.EqualFold(h, infohash) {
+			return cached, true
+		}
+	}
+	return false, false
+}
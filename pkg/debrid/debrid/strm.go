@@ -0,0 +1,79 @@
+package debrid
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+const strmExt = ".strm"
+
+// StrmName returns the name of the .strm file of a media file, e.g. "Movie.mkv" is "Movie.strm"
+func StrmName(filename string) string {
+	return strings.TrimSuffix(filename, path.Ext(filename)) + strmExt
+}
+
+// StrmURL returns the address a .strm file points at. It redirects to a fresh download link of the file
+// when played, see the stream route of the WebDAV server.
+func (c *Cache) StrmURL(torrentName, filename string) string {
+	cfg := config.Get()
+	base := c.config.StrmURL
+	if base == "" {
+		base = fmt.Sprintf("http://localhost:%s", cfg.Port)
+	}
+	return strings.TrimSuffix(base, "/") + path.Join(cfg.URLBase, "webdav", "stream", url.PathEscape(c.config.Name),
+		url.PathEscape(torrentName), url.PathEscape(filename))
+}
+
+// IsStrmFolder reports whether a custom folder serves .strm files instead of the media files
+func (c *Cache) IsStrmFolder(folder string) bool {
+	dir, ok := c.config.Directories[folder]
+	return ok && dir.SaveStrms
+}
+
+// GetStrmFiles returns the .strm files of a torrent, keyed by their name, with the name of the media file
+// they point at. Files that aren't media are left out.
+func (c *Cache) GetStrmFiles(torrent *types.Torrent) map[string]string {
+	files := make(map[string]string, len(torrent.Files))
+	// walk the files in order so a clash always resolves the same way
+	names := make([]string, 0, len(torrent.Files))
+	for name := range torrent.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !utils.IsMediaFile(name) || path.Ext(name) == strmExt {
+			continue
+		}
+		if _, exists := files[StrmName(name)]; !exists {
+			files[StrmName(name)] = name
+		}
+	}
+	return files
+}
+
+// GetStrmListing returns the listing of a torrent folder in a .strm folder
+func (c *Cache) GetStrmListing(torrentName string, torrent *CachedTorrent) []os.FileInfo {
+	files := c.GetStrmFiles(torrent.Torrent)
+	listing := make([]os.FileInfo, 0, len(files))
+	for strmName, filename := range files {
+		listing = append(listing, &fileInfo{
+			id:      torrent.Id,
+			name:    strmName,
+			size:    int64(len(c.StrmURL(torrentName, filename))),
+			mode:    0644,
+			modTime: torrent.AddedOn,
+		})
+	}
+	sort.Slice(listing, func(i, j int) bool {
+		return listing[i].Name() < listing[j].Name()
+	})
+	return listing
+}
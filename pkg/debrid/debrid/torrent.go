@@ -3,43 +3,14 @@ package debrid
 import (
 	"fmt"
 	"os"
-	"regexp"
 	"sort"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-)
-
-const (
-	filterByInclude string = "include"
-	filterByExclude string = "exclude"
-
-	filterByStartsWith    string = "starts_with"
-	filterByEndsWith      string = "ends_with"
-	filterByNotStartsWith string = "not_starts_with"
-	filterByNotEndsWith   string = "not_ends_with"
-
-	filterByRegex    string = "regex"
-	filterByNotRegex string = "not_regex"
-
-	filterByExactMatch    string = "exact_match"
-	filterByNotExactMatch string = "not_exact_match"
-
-	filterBySizeGT string = "size_gt"
-	filterBySizeLT string = "size_lt"
 
-	filterBLastAdded string = "last_added"
+	"github.com/sirrobot01/decypharr/internal/config"
 )
 
-type directoryFilter struct {
-	filterType    string
-	value         string
-	regex         *regexp.Regexp // only for regex/not_regex
-	sizeThreshold int64          // only for size_gt/size_lt
-	ageThreshold  time.Duration  // only for last_added
-}
-
 type torrentCache struct {
 	mu                 sync.Mutex
 	byID               map[string]CachedTorrent
@@ -47,8 +18,11 @@ type torrentCache struct {
 	listing            atomic.Value
 	folderListing      map[string][]os.FileInfo
 	folderListingMu    sync.RWMutex
-	directoriesFilters map[string][]directoryFilter
+	directoriesFilters map[string]*config.DirectoryFilter
 	sortNeeded         atomic.Bool
+	// library is the parsed view of the torrents, nil when it's disabled
+	library        atomic.Pointer[mediaLibrary]
+	libraryEnabled bool
 }
 
 type sortableFile struct {
@@ -57,19 +31,25 @@ type sortableFile struct {
 	modTime time.Time
 	size    int64
 	bad     bool
+	// subject is what the folder filters match
+	subject config.FilterSubject
 }
 
-func newTorrentCache(dirFilters map[string][]directoryFilter) *torrentCache {
+func newTorrentCache(dirFilters map[string]*config.DirectoryFilter, library bool) *torrentCache {
 
 	tc := &torrentCache{
 		byID:               make(map[string]CachedTorrent),
 		byName:             make(map[string]CachedTorrent),
 		folderListing:      make(map[string][]os.FileInfo),
 		directoriesFilters: dirFilters,
+		libraryEnabled:     library,
 	}
 
 	tc.sortNeeded.Store(false)
 	tc.listing.Store(make([]os.FileInfo, 0))
+	if library {
+		tc.library.Store(newMediaLibrary())
+	}
 	return tc
 }
 
@@ -87,6 +67,10 @@ func (tc *torrentCache) reset() {
 	tc.folderListingMu.Lock()
 	tc.folderListing = make(map[string][]os.FileInfo)
 	tc.folderListingMu.Unlock()
+
+	if tc.libraryEnabled {
+		tc.library.Store(newMediaLibrary())
+	}
 }
 
 func (tc *torrentCache) getByID(id string) (CachedTorrent, bool) {
@@ -136,12 +120,23 @@ func (tc *torrentCache) getFolderListing(folderName string) []os.FileInfo {
 	return []os.FileInfo{}
 }
 
+func (tc *torrentCache) getLibrary() *mediaLibrary {
+	return tc.library.Load()
+}
+
 func (tc *torrentCache) refreshListing() {
 
 	tc.mu.Lock()
 	all := make([]sortableFile, 0, len(tc.byName))
+	var byName map[string]CachedTorrent
+	if tc.libraryEnabled {
+		byName = make(map[string]CachedTorrent, len(tc.byName))
+	}
 	for name, t := range tc.byName {
-		all = append(all, sortableFile{t.Id, name, t.AddedOn, t.Bytes, t.Bad})
+		all = append(all, sortableFile{t.Id, name, t.AddedOn, t.Bytes, t.Bad, filterSubject(name, t)})
+		if byName != nil {
+			byName[name] = t
+		}
 	}
 	tc.sortNeeded.Store(false)
 	tc.mu.Unlock()
@@ -191,14 +186,22 @@ func (tc *torrentCache) refreshListing() {
 	}()
 	wg.Done()
 
+	if byName != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tc.library.Store(buildMediaLibrary(byName))
+		}()
+	}
+
 	now := time.Now()
 	wg.Add(len(tc.directoriesFilters)) // for each directory filter
-	for dir, filters := range tc.directoriesFilters {
-		go func(dir string, filters []directoryFilter) {
+	for dir, filter := range tc.directoriesFilters {
+		go func(dir string, filter *config.DirectoryFilter) {
 			defer wg.Done()
 			var matched []os.FileInfo
 			for _, sf := range all {
-				if tc.torrentMatchDirectory(filters, sf, now) {
+				if filter.Match(sf.subject, now) {
 					matched = append(matched, &fileInfo{
 						id:   sf.id,
 						name: sf.name, size: sf.size,
@@ -214,53 +217,29 @@ func (tc *torrentCache) refreshListing() {
 				delete(tc.folderListing, dir)
 			}
 			tc.folderListingMu.Unlock()
-		}(dir, filters)
+		}(dir, filter)
 	}
 
 	wg.Wait()
 }
 
-func (tc *torrentCache) torrentMatchDirectory(filters []directoryFilter, file sortableFile, now time.Time) bool {
-
-	torrentName := strings.ToLower(file.name)
-	for _, filter := range filters {
-		matched := false
-
-		switch filter.filterType {
-		case filterByInclude:
-			matched = strings.Contains(torrentName, filter.value)
-		case filterByStartsWith:
-			matched = strings.HasPrefix(torrentName, filter.value)
-		case filterByEndsWith:
-			matched = strings.HasSuffix(torrentName, filter.value)
-		case filterByExactMatch:
-			matched = torrentName == filter.value
-		case filterByExclude:
-			matched = !strings.Contains(torrentName, filter.value)
-		case filterByNotStartsWith:
-			matched = !strings.HasPrefix(torrentName, filter.value)
-		case filterByNotEndsWith:
-			matched = !strings.HasSuffix(torrentName, filter.value)
-		case filterByRegex:
-			matched = filter.regex.MatchString(torrentName)
-		case filterByNotRegex:
-			matched = !filter.regex.MatchString(torrentName)
-		case filterByNotExactMatch:
-			matched = torrentName != filter.value
-		case filterBySizeGT:
-			matched = file.size > filter.sizeThreshold
-		case filterBySizeLT:
-			matched = file.size < filter.sizeThreshold
-		case filterBLastAdded:
-			matched = file.modTime.After(now.Add(-filter.ageThreshold))
-		}
-		if !matched {
-			return false // All filters must match
-		}
+func filterSubject(name string, t CachedTorrent) config.FilterSubject {
+	files := make([]string, 0, len(t.Files))
+	for _, f := range t.Files {
+		files = append(files, f.Name)
 	}
-
-	// If we get here, all filters matched
-	return true
+	subject := config.FilterSubject{
+		Name:    name,
+		Files:   files,
+		Debrid:  t.Debrid,
+		Bad:     t.Bad,
+		Size:    t.Bytes,
+		AddedOn: t.AddedOn,
+	}
+	if t.Arr != nil {
+		subject.Arr = t.Arr.Name
+	}
+	return subject
 }
 
 func (tc *torrentCache) getAll() map[string]CachedTorrent {
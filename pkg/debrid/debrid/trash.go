@@ -0,0 +1,129 @@
+package debrid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirrobot01/decypharr/pkg/debrid/store"
+)
+
+var ErrTrashedTorrentNotFound = errors.New("trashed torrent not found")
+
+// TrashedTorrent is a torrent deleted from the cache. It is kept until the trash is purged.
+type TrashedTorrent struct {
+	CachedTorrent
+	Folder    string    `json:"folder"`
+	TrashedAt time.Time `json:"trashed_at"`
+	// size is the space the torrent takes in the store
+	size int64
+}
+
+func (c *Cache) decodeTrashed(t store.Trashed) (TrashedTorrent, error) {
+	var ct CachedTorrent
+	if err := json.Unmarshal(t.Data, &ct); err != nil {
+		return TrashedTorrent{}, err
+	}
+	if ct.Torrent == nil {
+		return TrashedTorrent{}, fmt.Errorf("trashed torrent %s is empty", t.ID)
+	}
+	ct.Id = t.ID
+	return TrashedTorrent{
+		CachedTorrent: ct,
+		Folder:        c.GetTorrentFolder(ct.Torrent),
+		TrashedAt:     t.TrashedAt,
+		size:          int64(len(t.Data)),
+	}, nil
+}
+
+// GetTrash returns the trashed torrents, most recently deleted first
+func (c *Cache) GetTrash() []TrashedTorrent {
+	trash := make([]TrashedTorrent, 0)
+	err := c.store.ForEachTrashed(func(t store.Trashed) error {
+		tt, err := c.decodeTrashed(t)
+		if err != nil {
+			c.logger.Debug().Err(err).Msgf("Skipping trashed torrent %s", t.ID)
+			return nil
+		}
+		trash = append(trash, tt)
+		return nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to read trash")
+	}
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].TrashedAt.After(trash[j].TrashedAt)
+	})
+	return trash
+}
+
+// GetTrashedTorrent returns a torrent from the trash
+func (c *Cache) GetTrashedTorrent(id string) (*TrashedTorrent, error) {
+	t, err := c.store.GetTrashed(id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrTrashedTorrentNotFound
+		}
+		return nil, err
+	}
+	tt, err := c.decodeTrashed(t)
+	if err != nil {
+		return nil, err
+	}
+	return &tt, nil
+}
+
+// RestoreTorrent submits the magnet of a trashed torrent again and serves it under its old folder name
+func (c *Cache) RestoreTorrent(id string) (*CachedTorrent, error) {
+	trashed, err := c.GetTrashedTorrent(id)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := c.resubmit(trashed.Torrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore torrent: %w", err)
+	}
+	if err := c.store.DeleteTrashed(id); err != nil {
+		c.logger.Error().Err(err).Msgf("Failed to remove restored torrent %s from trash", id)
+	}
+	c.logger.Info().Msgf("Restored torrent %s from trash as %s", id, restored.Id)
+	return restored, nil
+}
+
+// DeleteTrashedTorrent removes a torrent from the trash for good
+func (c *Cache) DeleteTrashedTorrent(id string) error {
+	if _, err := c.store.GetTrashed(id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrTrashedTorrentNotFound
+		}
+		return err
+	}
+	return c.store.DeleteTrashed(id)
+}
+
+// purgeTrash removes the torrents trashed longer than the retention, then the oldest ones
+// until the trash fits in its maximum size
+func (c *Cache) purgeTrash() {
+	trash := c.GetTrash()
+	now := time.Now()
+	var total int64
+	purge := make([]string, 0)
+	// trash is sorted newest first, the oldest torrents are the ones past the size limit
+	for _, t := range trash {
+		expired := c.trashRetention > 0 && now.Sub(t.TrashedAt) > c.trashRetention
+		total += t.size
+		if expired || (c.trashMaxSize > 0 && total > c.trashMaxSize) {
+			purge = append(purge, t.Id)
+		}
+	}
+	if len(purge) == 0 {
+		return
+	}
+	if err := c.store.DeleteTrashed(purge...); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to purge trash")
+		return
+	}
+	c.logger.Info().Msgf("Purged %d torrents from trash", len(purge))
+}
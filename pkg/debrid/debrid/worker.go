@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/go-co-op/gocron/v2"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 )
 
 func (c *Cache) StartSchedule(ctx context.Context) error {
@@ -37,6 +38,65 @@ func (c *Cache) StartSchedule(ctx context.Context) error {
 		}
 	}
 
+	// Incremental refreshes don't see deleted torrents, reconcile the full listing less often
+	if _, ok := c.client.(types.IncrementalLister); ok {
+		if jd, err := utils.ConvertToJobDef(c.torrentFullRefreshInterval); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to convert full torrent refresh interval to job definition")
+		} else {
+			// Schedule the job
+			if _, err := c.scheduler.NewJob(jd, gocron.NewTask(func() {
+				c.refreshTorrentsFull(ctx)
+			}), gocron.WithContext(ctx)); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to create full torrent refresh job")
+			} else {
+				c.logger.Debug().Msgf("Full torrent refresh job scheduled for every %s", c.torrentFullRefreshInterval)
+			}
+		}
+	}
+
+	// Check the health of the cached torrents, a batch every minute
+	if c.scanner.interval > 0 {
+		if jd, err := utils.ConvertToJobDef("1m"); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to convert health scan interval to job definition")
+		} else {
+			if _, err := c.scheduler.NewJob(jd, gocron.NewTask(func() {
+				c.scanHealth(ctx)
+			}), gocron.WithContext(ctx)); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to create health scan job")
+			} else {
+				c.logger.Debug().Msgf("Health scan job scheduled for every %s", c.scanner.interval)
+			}
+		}
+	}
+
+	// Keep the links of recently opened files and new torrents warm
+	if c.warmer.limiter != nil {
+		if jd, err := utils.ConvertToJobDef("1m"); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to convert link prefetch interval to job definition")
+		} else {
+			if _, err := c.scheduler.NewJob(jd, gocron.NewTask(func() {
+				c.warmLinks(ctx)
+			}), gocron.WithContext(ctx)); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to create link prefetch job")
+			} else {
+				c.logger.Debug().Msgf("Link prefetch job scheduled for every minute")
+			}
+		}
+	}
+
+	// Retry the failed reinserts whose backoff elapsed
+	if jd, err := utils.ConvertToJobDef("1m"); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to convert reinsert retry interval to job definition")
+	} else {
+		if _, err := c.scheduler.NewJob(jd, gocron.NewTask(func() {
+			c.retryReinserts()
+		}), gocron.WithContext(ctx)); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to create reinsert retry job")
+		} else {
+			c.logger.Debug().Msgf("Reinsert retry job scheduled for every minute")
+		}
+	}
+
 	// Schedule the reset invalid links job
 	// This job will run every at 00:00 CET
 	// and reset the invalid links in the cache
@@ -53,6 +113,22 @@ func (c *Cache) StartSchedule(ctx context.Context) error {
 		}
 	}
 
+	// Purge the trash and reclaim the space left by deleted torrents in the cache store, daily
+	if jd, err := utils.ConvertToJobDef("04:00"); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to convert store compaction interval to job definition")
+	} else {
+		if _, err := c.scheduler.NewJob(jd, gocron.NewTask(func() {
+			c.purgeTrash()
+			if err := c.store.Compact(); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to compact cache store")
+			}
+		}), gocron.WithContext(ctx)); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to create store compaction job")
+		} else {
+			c.logger.Debug().Msgf("Store compaction job scheduled for every day at 04:00")
+		}
+	}
+
 	// Start the scheduler
 	c.scheduler.Start()
 	c.cetScheduler.Start()
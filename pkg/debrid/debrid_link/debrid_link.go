@@ -9,8 +9,10 @@ import (
 	"github.com/sirrobot01/decypharr/internal/logger"
 	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/account"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"strconv"
+	"sync"
 	"time"
 
 	"net/http"
@@ -21,9 +23,15 @@ type DebridLink struct {
 	Name             string
 	Host             string `json:"host"`
 	APIKey           string
-	accounts         map[string]types.Account
+	accounts         *account.Manager
 	DownloadUncached bool
 	client           *request.Client
+	downloadClient   *request.Client
+
+	// mainAccountId is the download key matching APIKey, the seedbox links belong to it
+	mainAccountId string
+	// linkAccounts maps downloader link ids to the key that created them
+	linkAccounts sync.Map
 
 	MountPath   string
 	logger      zerolog.Logger
@@ -66,7 +74,7 @@ func (dl *DebridLink) IsAvailable(hashes []string) map[string]bool {
 		hashStr := strings.Join(validHashes, ",")
 		url := fmt.Sprintf("%s/seedbox/cached/%s", dl.Host, hashStr)
 		req, _ := http.NewRequest(http.MethodGet, url, nil)
-		resp, err := dl.client.MakeRequest(req)
+		resp, err := makeRequest(dl.client, req)
 		if err != nil {
 			dl.logger.Info().Msgf("Error checking availability: %v", err)
 			return result
@@ -77,15 +85,13 @@ func (dl *DebridLink) IsAvailable(hashes []string) map[string]bool {
 			dl.logger.Info().Msgf("Error marshalling availability: %v", err)
 			return result
 		}
-		if data.Value == nil {
-			return result
-		}
-		value := *data.Value
-		for _, h := range hashes[i:end] {
-			_, exists := value[h]
-			if exists {
-				result[h] = true
+		// Only the cached hashes are listed
+		for _, h := range validHashes {
+			exists := false
+			if data.Value != nil {
+				_, exists = (*data.Value)[h]
 			}
+			result[h] = exists
 		}
 	}
 	return result
@@ -94,7 +100,7 @@ func (dl *DebridLink) IsAvailable(hashes []string) map[string]bool {
 func (dl *DebridLink) GetTorrent(torrentId string) (*types.Torrent, error) {
 	url := fmt.Sprintf("%s/seedbox/%s", dl.Host, torrentId)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := dl.client.MakeRequest(req)
+	resp, err := makeRequest(dl.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -140,7 +146,7 @@ func (dl *DebridLink) GetTorrent(torrentId string) (*types.Torrent, error) {
 				Link:         f.DownloadURL,
 				DownloadLink: f.DownloadURL,
 				Generated:    time.Now(),
-				AccountId:    "0",
+				AccountId:    dl.mainAccountId,
 			},
 			Link: f.DownloadURL,
 		}
@@ -153,7 +159,7 @@ func (dl *DebridLink) GetTorrent(torrentId string) (*types.Torrent, error) {
 func (dl *DebridLink) UpdateTorrent(t *types.Torrent) error {
 	url := fmt.Sprintf("%s/seedbox/list?ids=%s", dl.Host, t.Id)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := dl.client.MakeRequest(req)
+	resp, err := makeRequest(dl.client, req)
 	if err != nil {
 		return err
 	}
@@ -206,7 +212,7 @@ func (dl *DebridLink) UpdateTorrent(t *types.Torrent) error {
 				Link:         f.DownloadURL,
 				DownloadLink: f.DownloadURL,
 				Generated:    time.Now(),
-				AccountId:    "0",
+				AccountId:    dl.mainAccountId,
 			},
 			Link: f.DownloadURL,
 		}
@@ -220,7 +226,7 @@ func (dl *DebridLink) SubmitMagnet(t *types.Torrent) (*types.Torrent, error) {
 	payload := map[string]string{"url": t.Magnet.Link}
 	jsonPayload, _ := json.Marshal(payload)
 	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonPayload))
-	resp, err := dl.client.MakeRequest(req)
+	resp, err := makeRequest(dl.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +267,7 @@ func (dl *DebridLink) SubmitMagnet(t *types.Torrent) (*types.Torrent, error) {
 				Link:         f.DownloadURL,
 				DownloadLink: f.DownloadURL,
 				Generated:    time.Now(),
-				AccountId:    "0",
+				AccountId:    dl.mainAccountId,
 			},
 			Generated: time.Now(),
 		}
@@ -303,7 +309,7 @@ func (dl *DebridLink) CheckStatus(torrent *types.Torrent, isSymlink bool) (*type
 func (dl *DebridLink) DeleteTorrent(torrentId string) error {
 	url := fmt.Sprintf("%s/seedbox/%s/remove", dl.Host, torrentId)
 	req, _ := http.NewRequest(http.MethodDelete, url, nil)
-	if _, err := dl.client.MakeRequest(req); err != nil {
+	if _, err := makeRequest(dl.client, req); err != nil {
 		return err
 	}
 	dl.logger.Info().Msgf("Torrent: %s deleted from DebridLink", torrentId)
@@ -319,8 +325,44 @@ func (dl *DebridLink) GetDownloads() (map[string]types.DownloadLink, error) {
 	return nil, nil
 }
 
+// GetDownloadLink returns the seedbox link when the least used download key is the main one.
+// Other keys unrestrict the file through the downloader, moving on to the next key when one is out of traffic.
 func (dl *DebridLink) GetDownloadLink(t *types.Torrent, file *types.File) (*types.DownloadLink, error) {
-	return file.DownloadLink, nil
+	return account.Rotate(dl.accounts, "traffic exceeded", func(acc account.Account) (*types.DownloadLink, error) {
+		if acc.ID == dl.mainAccountId && file.DownloadLink != nil {
+			return file.DownloadLink, nil
+		}
+		return dl.addDownloader(file, acc)
+	})
+}
+
+func (dl *DebridLink) addDownloader(file *types.File, acc account.Account) (*types.DownloadLink, error) {
+	url := fmt.Sprintf("%s/downloader/add", dl.Host)
+	payload := map[string]string{"url": file.Link}
+	jsonPayload, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonPayload))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", acc.Token))
+	resp, err := makeRequest(dl.downloadClient, req)
+	if err != nil {
+		return nil, err
+	}
+	var data downloaderInfo
+	if err = json.Unmarshal(resp, &data); err != nil {
+		return nil, err
+	}
+	if !data.Success || data.Value == nil {
+		return nil, fmt.Errorf("debridlink API error: empty downloader response")
+	}
+	dl.linkAccounts.Store(data.Value.ID, acc.Token)
+	return &types.DownloadLink{
+		Filename:     data.Value.Name,
+		Size:         data.Value.Size,
+		Link:         file.Link,
+		DownloadLink: data.Value.DownloadURL,
+		Id:           data.Value.ID,
+		Generated:    time.Now(),
+		AccountId:    acc.ID,
+	}, nil
 }
 
 func (dl *DebridLink) GetDownloadingStatus() []string {
@@ -335,7 +377,7 @@ func (dl *DebridLink) GetDownloadUncached() bool {
 	return dl.DownloadUncached
 }
 
-func New(dc config.Debrid) *DebridLink {
+func New(dc config.Debrid, opts ...request.ClientOption) *DebridLink {
 	rl := request.ParseRateLimit(dc.RateLimit)
 
 	headers := map[string]string{
@@ -343,29 +385,37 @@ func New(dc config.Debrid) *DebridLink {
 		"Content-Type":  "application/json",
 	}
 	_log := logger.New(dc.Name)
-	client := request.New(
+	client := request.New(append([]request.ClientOption{
 		request.WithHeaders(headers),
 		request.WithLogger(_log),
 		request.WithRateLimiter(rl),
 		request.WithProxy(dc.Proxy),
-	)
+	}, opts...)...)
+
+	// The Authorization header is set per request, with the key of the picked account
+	downloadClient := request.New(append([]request.ClientOption{
+		request.WithHeaders(map[string]string{"Content-Type": "application/json"}),
+		request.WithLogger(_log),
+		request.WithRateLimiter(rl),
+		request.WithProxy(dc.Proxy),
+	}, opts...)...)
 
-	accounts := make(map[string]types.Account)
+	mainAccountId := ""
 	for idx, key := range dc.DownloadAPIKeys {
-		id := strconv.Itoa(idx)
-		accounts[id] = types.Account{
-			Name:  key,
-			ID:    id,
-			Token: key,
+		if key == dc.APIKey {
+			mainAccountId = strconv.Itoa(idx)
+			break
 		}
 	}
 	return &DebridLink{
 		Name:             "debridlink",
 		Host:             "https://debrid-link.com/api/v2",
 		APIKey:           dc.APIKey,
-		accounts:         accounts,
+		accounts:         account.NewManager(dc.Name, dc.DownloadAPIKeys),
 		DownloadUncached: dc.DownloadUncached,
 		client:           client,
+		downloadClient:   downloadClient,
+		mainAccountId:    mainAccountId,
 		MountPath:        dc.Folder,
 		logger:           logger.New(dc.Name),
 		checkCached:      dc.CheckCached,
@@ -394,7 +444,7 @@ func (dl *DebridLink) GetTorrents() ([]*types.Torrent, error) {
 func (dl *DebridLink) getTorrents(page, perPage int) ([]*types.Torrent, error) {
 	url := fmt.Sprintf("%s/seedbox/list?page=%d&perPage=%d", dl.Host, page, perPage)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := dl.client.MakeRequest(req)
+	resp, err := makeRequest(dl.client, req)
 	torrents := make([]*types.Torrent, 0)
 	if err != nil {
 		return torrents, err
@@ -444,7 +494,7 @@ func (dl *DebridLink) getTorrents(page, perPage int) ([]*types.Torrent, error) {
 					Link:         f.DownloadURL,
 					DownloadLink: f.DownloadURL,
 					Generated:    time.Now(),
-					AccountId:    "0",
+					AccountId:    dl.mainAccountId,
 				},
 				Link: f.DownloadURL,
 			}
@@ -464,11 +514,28 @@ func (dl *DebridLink) GetMountPath() string {
 }
 
 func (dl *DebridLink) DisableAccount(accountId string) {
+	dl.accounts.Disable(accountId, "bandwidth exceeded")
 }
 
 func (dl *DebridLink) ResetActiveDownloadKeys() {
+	dl.accounts.Reset()
 }
 
+func (dl *DebridLink) Accounts() *account.Manager {
+	return dl.accounts
+}
+
+// DeleteDownloadLink removes a link created by the downloader. Seedbox links are left alone.
 func (dl *DebridLink) DeleteDownloadLink(linkId string) error {
+	token, ok := dl.linkAccounts.LoadAndDelete(linkId)
+	if !ok {
+		return nil
+	}
+	url := fmt.Sprintf("%s/downloader/%s/remove", dl.Host, linkId)
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	if _, err := makeRequest(dl.downloadClient, req); err != nil {
+		return err
+	}
 	return nil
 }
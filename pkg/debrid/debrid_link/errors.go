@@ -0,0 +1,61 @@
+package debrid_link
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+// errorKinds maps Debrid Link error codes, see https://debrid-link.com/api_doc/v2/errors
+var errorKinds = map[string]*request.HTTPError{
+	"badToken":           request.AuthInvalidError,
+	"accountLocked":      request.AuthInvalidError,
+	"notPremium":         request.AuthInvalidError,
+	"serverNotAllowed":   request.AuthInvalidError,
+	"floodDetected":      request.RateLimitedError,
+	"notFound":           request.TorrentNotFoundError,
+	"maxTorrent":         request.TooManyActiveDownloadsError,
+	"maxData":            request.TrafficExceededError,
+	"maxDataHost":        request.TrafficExceededError,
+	"maxLink":            request.TrafficExceededError,
+	"maxLinkHost":        request.TrafficExceededError,
+	"fileNotFound":       request.HosterUnavailableError,
+	"fileNotAvailable":   request.HosterUnavailableError,
+	"notDebrid":          request.UnknownError,
+	"hostNotValid":       request.UnknownError,
+	"notFreeHost":        request.UnknownError,
+	"maintenanceHost":    request.ServiceUnavailableError,
+	"noServerHost":       request.ServiceUnavailableError,
+	"disabledServerHost": request.ServiceUnavailableError,
+}
+
+// apiError maps a Debrid Link error code to a request error kind
+func apiError(statusCode int, code string) error {
+	kind, ok := errorKinds[code]
+	if !ok {
+		kind = request.StatusError(statusCode)
+	}
+	return request.ProviderError(kind, "debridlink API error: Status: %d || %s", statusCode, code)
+}
+
+// makeRequest is request.Client.MakeRequest, with Debrid Link error codes mapped
+func makeRequest(client *request.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	var data APIResponse[json.RawMessage]
+	_ = json.Unmarshal(body, &data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || data.Error != "" {
+		return nil, apiError(resp.StatusCode, data.Error)
+	}
+	return body, nil
+}
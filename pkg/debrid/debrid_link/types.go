@@ -1,8 +1,9 @@
 package debrid_link
 
 type APIResponse[T any] struct {
-	Success bool `json:"success"`
-	Value   *T   `json:"value"` // Use pointer to allow nil
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Value   *T     `json:"value"` // Use pointer to allow nil
 }
 
 type AvailableResponse APIResponse[map[string]map[string]struct {
@@ -43,3 +44,10 @@ type _torrentInfo struct {
 type torrentInfo APIResponse[[]_torrentInfo]
 
 type SubmitTorrentInfo APIResponse[_torrentInfo]
+
+type downloaderInfo APIResponse[struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DownloadURL string `json:"downloadUrl"`
+	Size        int64  `json:"size"`
+}]
@@ -0,0 +1,495 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+	"io"
+	"net/http"
+	gourl "net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generic is a debrid client driven by a config.DebridMapping
+type Generic struct {
+	Name             string
+	APIKey           string
+	DownloadUncached bool
+	client           *request.Client
+	mapping          config.DebridMapping
+
+	MountPath   string
+	logger      zerolog.Logger
+	checkCached bool
+	addSamples  bool
+}
+
+func New(dc config.Debrid, mapping config.DebridMapping, opts ...request.ClientOption) *Generic {
+	rl := request.ParseRateLimit(dc.RateLimit)
+
+	headers := map[string]string{}
+	if mapping.Auth.Header != "" {
+		headers[mapping.Auth.Header] = mapping.Auth.Prefix + dc.APIKey
+	}
+	_log := logger.New(dc.Name)
+	client := request.New(append([]request.ClientOption{
+		request.WithHeaders(headers),
+		request.WithLogger(_log),
+		request.WithRateLimiter(rl),
+		request.WithProxy(dc.Proxy),
+	}, opts...)...)
+
+	return &Generic{
+		Name:             dc.Name,
+		APIKey:           dc.APIKey,
+		DownloadUncached: dc.DownloadUncached,
+		client:           client,
+		mapping:          mapping,
+		MountPath:        dc.Folder,
+		logger:           _log,
+		checkCached:      dc.CheckCached,
+		addSamples:       dc.AddSamples,
+	}
+}
+
+// call sends the request described by the endpoint and returns the part of the response it points to
+func (g *Generic) call(e *config.DebridEndpoint, vars map[string]string) (any, error) {
+	url := g.mapping.Host + expand(e.URL, vars, gourl.PathEscape)
+	if g.mapping.Auth.Query != "" {
+		sep := "?"
+		if strings.Contains(url, "?") {
+			sep = "&"
+		}
+		url += sep + gourl.Values{g.mapping.Auth.Query: {g.APIKey}}.Encode()
+	}
+
+	var body io.Reader
+	if len(e.Form) > 0 {
+		form := gourl.Values{}
+		for key, value := range e.Form {
+			if value == "{hashes}" {
+				// One value per hash, e.g. hashes[]=a&hashes[]=b
+				for _, h := range strings.Split(vars["hashes"], ",") {
+					form.Add(key, h)
+				}
+				continue
+			}
+			form.Set(key, expand(value, vars, nil))
+		}
+		body = strings.NewReader(form.Encode())
+	}
+
+	method := e.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := g.client.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(resp)) == 0 {
+		return nil, nil
+	}
+
+	var data any
+	decoder := json.NewDecoder(bytes.NewReader(resp))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	if msg := extractString(data, g.mapping.Error); msg != "" {
+		return nil, request.ProviderError(request.UnknownError, "%s error: %s", g.Name, msg)
+	}
+	result, _ := extract(data, e.Result)
+	return result, nil
+}
+
+// expand replaces the {placeholders} in s, escaping the values with escape if set
+func expand(s string, vars map[string]string, escape func(string) string) string {
+	for key, value := range vars {
+		if escape != nil {
+			value = escape(value)
+		}
+		s = strings.ReplaceAll(s, "{"+key+"}", value)
+	}
+	return s
+}
+
+func (g *Generic) field(name string) string {
+	return g.mapping.TorrentFields[name]
+}
+
+func (g *Generic) status(data any) string {
+	status := extractString(data, g.field("status"))
+	if mapped, ok := g.mapping.StatusMap[status]; ok {
+		return mapped
+	}
+	return status
+}
+
+// fillTorrent copies the mapped fields of a torrent object to t
+func (g *Generic) fillTorrent(t *types.Torrent, data any) {
+	if id := extractString(data, g.field("id")); id != "" {
+		t.Id = id
+	}
+	if name := utils.RemoveInvalidChars(extractString(data, g.field("name"))); name != "" {
+		t.Name = name
+		t.Filename = name
+		t.OriginalFilename = name
+		t.Folder = name
+	}
+	if hash := extractString(data, g.field("hash")); hash != "" {
+		t.InfoHash = hash
+	} else if magnet := extractString(data, g.field("magnet")); magnet != "" && t.InfoHash == "" {
+		t.InfoHash = utils.ExtractInfoHash(magnet)
+	}
+	if status := g.status(data); status != "" {
+		t.Status = status
+	}
+	if size := extractInt(data, g.field("size")); size > 0 {
+		t.Bytes = size
+	}
+	t.Progress = extractFloat(data, g.field("progress"))
+	if t.Status == "downloaded" {
+		t.Progress = 100
+	}
+	t.Speed = extractInt(data, g.field("speed"))
+	t.Seeders = int(extractInt(data, g.field("seeders")))
+	if added, ok := extractTime(data, g.field("added")); ok {
+		t.Added = added.Format(time.RFC3339)
+	}
+	t.MountPath = g.MountPath
+	t.Debrid = g.Name
+}
+
+// fillFiles lists the files of a downloaded torrent, from the files endpoint or the torrent object itself
+func (g *Generic) fillFiles(t *types.Torrent, data any) error {
+	var items []any
+	link := extractString(data, g.field("link"))
+	if g.mapping.Files != nil {
+		result, err := g.call(g.mapping.Files, g.vars(t, nil))
+		if err != nil {
+			if link == "" {
+				return err
+			}
+			// Some providers, e.g. Offcloud, can't list single file downloads but link them on the torrent
+			g.logger.Debug().Err(err).Msgf("Failed to list files of %s, using its link", t.Name)
+		}
+		items, _ = result.([]any)
+	} else if p := g.field("files"); p != "" {
+		items = extractList(data, p)
+	}
+
+	if len(items) == 0 {
+		// Single file torrents may only expose a link on the torrent
+		if link != "" {
+			items = []any{map[string]any{"name": t.Name, "size": json.Number(strconv.FormatInt(t.Bytes, 10)), "link": link}}
+			return g.addFiles(t, items, map[string]string{"name": "name", "size": "size", "link": "link"})
+		}
+	}
+	return g.addFiles(t, items, g.mapping.FileFields)
+}
+
+func (g *Generic) addFiles(t *types.Torrent, items []any, fields map[string]string) error {
+	cfg := config.Get()
+	files := make(map[string]types.File, len(items))
+	for idx, item := range items {
+		link := extractString(item, fields["link"])
+		filePath := extractString(item, fields["path"])
+		if filePath == "" {
+			filePath = extractString(item, fields["name"])
+		}
+		if filePath == "" && link != "" {
+			// Providers listing files as bare links, the name is the last part of the link
+			filePath, _ = gourl.PathUnescape(path.Base(link))
+		}
+		fileName := filepath.Base(filePath)
+		size := extractInt(item, fields["size"])
+
+		if !g.addSamples && utils.IsSampleFile(filePath) {
+			continue
+		}
+		if !cfg.IsAllowedFile(fileName) || (size > 0 && !cfg.IsSizeAllowed(size)) {
+			continue
+		}
+		id := extractString(item, fields["id"])
+		if id == "" {
+			id = strconv.Itoa(idx)
+		}
+		file := types.File{
+			TorrentId: t.Id,
+			Id:        id,
+			Name:      fileName,
+			Size:      size,
+			Path:      filePath,
+			Link:      link,
+			Generated: time.Now(),
+		}
+		if g.mapping.Unrestrict == nil {
+			// Links are direct, no need to unrestrict them
+			file.DownloadLink = &types.DownloadLink{
+				Filename:     fileName,
+				Link:         link,
+				DownloadLink: link,
+				Size:         size,
+				Id:           id,
+				Generated:    time.Now(),
+				AccountId:    "0",
+			}
+		}
+		if _, ok := files[file.Name]; ok {
+			// File already exists, use path as key
+			files[file.Path] = file
+		} else {
+			files[file.Name] = file
+		}
+	}
+	t.Files = files
+	return nil
+}
+
+func (g *Generic) vars(t *types.Torrent, file *types.File) map[string]string {
+	vars := map[string]string{
+		"id":   t.Id,
+		"hash": t.InfoHash,
+	}
+	if t.Magnet != nil {
+		vars["magnet"] = t.Magnet.Link
+	}
+	if file != nil {
+		vars["link"] = file.Link
+	}
+	return vars
+}
+
+func (g *Generic) GetName() string {
+	return g.Name
+}
+
+func (g *Generic) GetLogger() zerolog.Logger {
+	return g.logger
+}
+
+func (g *Generic) IsAvailable(hashes []string) map[string]bool {
+	result := make(map[string]bool)
+	if g.mapping.Availability == nil {
+		return result
+	}
+	validHashes := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		if h != "" {
+			validHashes = append(validHashes, h)
+		}
+	}
+	if len(validHashes) == 0 {
+		return result
+	}
+	data, err := g.call(g.mapping.Availability, map[string]string{"hashes": strings.Join(validHashes, ",")})
+	if err != nil {
+		g.logger.Info().Msgf("Error checking availability: %v", err)
+		return result
+	}
+	// Only the cached hashes are listed
+	list, _ := data.([]any)
+	for _, h := range validHashes {
+		result[h] = false
+		for _, v := range list {
+			if strings.EqualFold(h, extractString(v, "$")) {
+				result[h] = true
+			}
+		}
+	}
+	return result
+}
+
+func (g *Generic) SubmitMagnet(t *types.Torrent) (*types.Torrent, error) {
+	data, err := g.call(g.mapping.AddMagnet, g.vars(t, nil))
+	if err != nil {
+		return nil, err
+	}
+	id := extractString(data, g.field("id"))
+	if id == "" {
+		return nil, fmt.Errorf("error adding torrent")
+	}
+	t.Id = id
+	t.MountPath = g.MountPath
+	t.Debrid = g.Name
+	return t, nil
+}
+
+func (g *Generic) GetTorrent(torrentId string) (*types.Torrent, error) {
+	t := &types.Torrent{
+		Id:    torrentId,
+		Files: make(map[string]types.File),
+	}
+	if err := g.UpdateTorrent(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (g *Generic) UpdateTorrent(t *types.Torrent) error {
+	data, err := g.call(g.mapping.Status, g.vars(t, nil))
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return request.TorrentNotFoundError
+	}
+	g.fillTorrent(t, data)
+	if t.Status != "downloaded" {
+		return nil
+	}
+	return g.fillFiles(t, data)
+}
+
+func (g *Generic) CheckStatus(torrent *types.Torrent, isSymlink bool) (*types.Torrent, error) {
+	for {
+		err := g.UpdateTorrent(torrent)
+		if err != nil || torrent == nil {
+			return torrent, err
+		}
+		status := torrent.Status
+		if status == "downloaded" {
+			g.logger.Info().Msgf("Torrent: %s downloaded", torrent.Name)
+			if !isSymlink {
+				if err = g.GenerateDownloadLinks(torrent); err != nil {
+					return torrent, err
+				}
+			}
+			break
+		} else if utils.Contains(g.GetDownloadingStatus(), status) {
+			if !torrent.DownloadUncached {
+				return torrent, fmt.Errorf("torrent: %s not cached", torrent.Name)
+			}
+			// Break out of the loop if the torrent is downloading.
+			// This is necessary to prevent infinite loop since we moved to sync downloading and async processing
+			return torrent, nil
+		} else {
+			return torrent, fmt.Errorf("torrent: %s has error", torrent.Name)
+		}
+
+	}
+	return torrent, nil
+}
+
+func (g *Generic) DeleteTorrent(torrentId string) error {
+	if _, err := g.call(g.mapping.Delete, map[string]string{"id": torrentId}); err != nil {
+		return err
+	}
+	g.logger.Info().Msgf("Torrent: %s deleted from %s", torrentId, g.Name)
+	return nil
+}
+
+func (g *Generic) GenerateDownloadLinks(t *types.Torrent) error {
+	if g.mapping.Unrestrict == nil {
+		// Download links are already generated
+		return nil
+	}
+	for name, file := range t.Files {
+		link, err := g.GetDownloadLink(t, &file)
+		if err != nil {
+			return err
+		}
+		file.DownloadLink = link
+		t.Files[name] = file
+	}
+	return nil
+}
+
+func (g *Generic) GetDownloadLink(t *types.Torrent, file *types.File) (*types.DownloadLink, error) {
+	if g.mapping.Unrestrict == nil {
+		if file.DownloadLink == nil {
+			return nil, request.ErrLinkBroken
+		}
+		return file.DownloadLink, nil
+	}
+	data, err := g.call(g.mapping.Unrestrict, g.vars(t, file))
+	if err != nil {
+		return nil, err
+	}
+	link := extractString(data, "$")
+	if link == "" {
+		return nil, fmt.Errorf("download link is empty")
+	}
+	return &types.DownloadLink{
+		Filename:     file.Name,
+		Link:         file.Link,
+		DownloadLink: link,
+		Size:         file.Size,
+		Id:           file.Id,
+		Generated:    time.Now(),
+		AccountId:    "0",
+	}, nil
+}
+
+func (g *Generic) GetTorrents() ([]*types.Torrent, error) {
+	torrents := make([]*types.Torrent, 0)
+	data, err := g.call(g.mapping.List, nil)
+	if err != nil {
+		return torrents, err
+	}
+	list, _ := data.([]any)
+	for _, item := range list {
+		t := &types.Torrent{
+			Files: make(map[string]types.File),
+		}
+		g.fillTorrent(t, item)
+		if t.Id == "" || t.Status != "downloaded" {
+			continue
+		}
+		torrents = append(torrents, t)
+	}
+	return torrents, nil
+}
+
+func (g *Generic) GetDownloads() (map[string]types.DownloadLink, error) {
+	return nil, nil
+}
+
+func (g *Generic) GetDownloadingStatus() []string {
+	return []string{"downloading"}
+}
+
+func (g *Generic) GetCheckCached() bool {
+	return g.checkCached
+}
+
+func (g *Generic) GetDownloadUncached() bool {
+	return g.DownloadUncached
+}
+
+func (g *Generic) CheckLink(link string) error {
+	return nil
+}
+
+func (g *Generic) GetMountPath() string {
+	return g.MountPath
+}
+
+func (g *Generic) DisableAccount(accountId string) {
+}
+
+func (g *Generic) ResetActiveDownloadKeys() {
+}
+
+func (g *Generic) DeleteDownloadLink(linkId string) error {
+	return nil
+}
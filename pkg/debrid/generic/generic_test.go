@@ -0,0 +1,360 @@
+package generic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "generic")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"log_level": "error"}`), 0644); err != nil {
+		panic(err)
+	}
+	config.SetConfigPath(dir)
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testMapping describes a provider answering with {"data": ...} or {"error": "..."}
+func testMapping(host string) config.DebridMapping {
+	return config.DebridMapping{
+		Host: host,
+		Auth: config.DebridMappingAuth{Header: "Authorization", Prefix: "Bearer "},
+		AddMagnet: &config.DebridEndpoint{
+			Method: http.MethodPost,
+			URL:    "/torrents",
+			Form:   map[string]string{"magnet": "{magnet}"},
+			Result: "data",
+		},
+		Status: &config.DebridEndpoint{
+			URL:    "/torrents/{id}",
+			Result: "data",
+		},
+		List: &config.DebridEndpoint{
+			URL:    "/torrents",
+			Result: "data",
+		},
+		Delete: &config.DebridEndpoint{
+			Method: http.MethodDelete,
+			URL:    "/torrents/{id}",
+		},
+		Unrestrict: &config.DebridEndpoint{
+			Method: http.MethodPost,
+			URL:    "/unrestrict",
+			Form:   map[string]string{"link": "{link}"},
+			Result: "data.download",
+		},
+		Availability: &config.DebridEndpoint{
+			Method: http.MethodPost,
+			URL:    "/cached",
+			Form:   map[string]string{"hashes[]": "{hashes}"},
+			Result: "data",
+		},
+		Error: "error",
+		TorrentFields: map[string]string{
+			"id":       "id",
+			"name":     "name",
+			"hash":     "hash",
+			"status":   "state",
+			"progress": "progress",
+			"size":     "bytes",
+			"files":    "files",
+		},
+		FileFields: map[string]string{
+			"id":   "id",
+			"path": "path",
+			"size": "size",
+			"link": "link",
+		},
+		StatusMap: map[string]string{
+			"finished": "downloaded",
+			"queued":   "downloading",
+			"failed":   "error",
+		},
+	}
+}
+
+func newTestGeneric(t *testing.T, handler http.HandlerFunc) *Generic {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	dc := config.Debrid{Name: "test", APIKey: "secret"}
+	return New(dc, testMapping(server.URL), request.WithMaxRetries(0))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSubmitMagnet(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/torrents" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer secret")
+		}
+		if got := r.FormValue("magnet"); got != "magnet:?xt=urn:btih:abc" {
+			t.Errorf("magnet = %q", got)
+		}
+		writeJSON(w, map[string]any{"data": map[string]any{"id": 42}})
+	})
+
+	torrent, err := g.SubmitMagnet(&types.Torrent{Magnet: &utils.Magnet{Link: "magnet:?xt=urn:btih:abc"}})
+	if err != nil {
+		t.Fatalf("SubmitMagnet: %v", err)
+	}
+	if torrent.Id != "42" {
+		t.Errorf("Id = %q, want %q", torrent.Id, "42")
+	}
+	if torrent.Debrid != "test" {
+		t.Errorf("Debrid = %q, want %q", torrent.Debrid, "test")
+	}
+}
+
+func TestSubmitMagnetWithoutId(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"data": map[string]any{}})
+	})
+	if _, err := g.SubmitMagnet(&types.Torrent{}); err == nil {
+		t.Fatal("SubmitMagnet succeeded without a torrent id")
+	}
+}
+
+func TestGetTorrent(t *testing.T) {
+	tests := []struct {
+		name         string
+		torrent      map[string]any
+		wantStatus   string
+		wantProgress float64
+		wantFiles    []string
+	}{
+		{
+			name: "downloaded",
+			torrent: map[string]any{
+				"id": "1", "name": "Movie.2020", "hash": "abc", "state": "finished", "progress": 99.5, "bytes": 300,
+				"files": []any{
+					map[string]any{"id": 7, "path": "Movie.2020/Movie.2020.mkv", "size": 200, "link": "https://host/f/7"},
+					map[string]any{"id": 8, "path": "Movie.2020/Movie.2020.nfo", "size": 100, "link": "https://host/f/8"},
+				},
+			},
+			wantStatus:   "downloaded",
+			wantProgress: 100,
+			wantFiles:    []string{"Movie.2020.mkv"},
+		},
+		{
+			name:         "downloading",
+			torrent:      map[string]any{"id": "1", "name": "Movie.2020", "state": "queued", "progress": "12.5"},
+			wantStatus:   "downloading",
+			wantProgress: 12.5,
+		},
+		{
+			name:       "unmapped status",
+			torrent:    map[string]any{"id": "1", "name": "Movie.2020", "state": "paused"},
+			wantStatus: "paused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/torrents/1" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				writeJSON(w, map[string]any{"data": tt.torrent})
+			})
+			torrent, err := g.GetTorrent("1")
+			if err != nil {
+				t.Fatalf("GetTorrent: %v", err)
+			}
+			if torrent.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", torrent.Status, tt.wantStatus)
+			}
+			if torrent.Progress != tt.wantProgress {
+				t.Errorf("Progress = %v, want %v", torrent.Progress, tt.wantProgress)
+			}
+			if len(torrent.Files) != len(tt.wantFiles) {
+				t.Fatalf("got %d files, want %d", len(torrent.Files), len(tt.wantFiles))
+			}
+			for _, name := range tt.wantFiles {
+				f, ok := torrent.Files[name]
+				if !ok {
+					t.Fatalf("missing file %s", name)
+				}
+				if f.TorrentId != "1" || f.Id != "7" || f.Size != 200 || f.Link != "https://host/f/7" {
+					t.Errorf("unexpected file %+v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTorrentFilesEndpointFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		torrent map[string]any
+		wantErr bool
+	}{
+		{
+			name:    "single file link",
+			torrent: map[string]any{"id": "1", "name": "Movie.2020.mkv", "state": "finished", "bytes": 200, "link": "https://host/f/1"},
+		},
+		{
+			name:    "no link",
+			torrent: map[string]any{"id": "1", "name": "Movie.2020", "state": "finished", "bytes": 200},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/torrents/1":
+					writeJSON(w, map[string]any{"data": tt.torrent})
+				case "/torrents/1/files":
+					// Like Offcloud's explore endpoint for single file downloads
+					writeJSON(w, map[string]any{"error": "Bad archive"})
+				default:
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+			})
+			g.mapping.Files = &config.DebridEndpoint{URL: "/torrents/{id}/files", Result: "data"}
+			g.mapping.TorrentFields["link"] = "link"
+
+			torrent, err := g.GetTorrent("1")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetTorrent succeeded, want the files endpoint error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetTorrent: %v", err)
+			}
+			f, ok := torrent.Files["Movie.2020.mkv"]
+			if !ok || len(torrent.Files) != 1 {
+				t.Fatalf("Files = %v, want the single file link", torrent.Files)
+			}
+			if f.Link != "https://host/f/1" || f.Size != 200 {
+				t.Errorf("unexpected file %+v", f)
+			}
+		})
+	}
+}
+
+func TestCheckStatusNotCached(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"data": map[string]any{"id": "1", "state": "queued"}})
+	})
+	if _, err := g.CheckStatus(&types.Torrent{Id: "1"}, true); err == nil || !strings.Contains(err.Error(), "not cached") {
+		t.Fatalf("CheckStatus error = %v, want not cached", err)
+	}
+}
+
+func TestGetDownloadLink(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/unrestrict" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.FormValue("link"); got != "https://host/f/7" {
+			t.Errorf("link = %q", got)
+		}
+		writeJSON(w, map[string]any{"data": map[string]any{"download": "https://cdn/f/7.mkv"}})
+	})
+	file := &types.File{Id: "7", Name: "Movie.mkv", Size: 200, Link: "https://host/f/7"}
+	link, err := g.GetDownloadLink(&types.Torrent{Id: "1"}, file)
+	if err != nil {
+		t.Fatalf("GetDownloadLink: %v", err)
+	}
+	if link.DownloadLink != "https://cdn/f/7.mkv" || link.Link != file.Link || link.Id != "7" {
+		t.Errorf("unexpected link %+v", link)
+	}
+}
+
+func TestGetDownloadLinkDirect(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+	g.mapping.Unrestrict = nil
+
+	direct := &types.DownloadLink{DownloadLink: "https://host/f/7"}
+	link, err := g.GetDownloadLink(&types.Torrent{}, &types.File{DownloadLink: direct})
+	if err != nil || link != direct {
+		t.Errorf("GetDownloadLink = %v, %v, want the file's link", link, err)
+	}
+	if _, err := g.GetDownloadLink(&types.Torrent{}, &types.File{}); !errors.Is(err, request.ErrLinkBroken) {
+		t.Errorf("GetDownloadLink without a link = %v, want %v", err, request.ErrLinkBroken)
+	}
+}
+
+func TestIsAvailable(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if got := r.PostForm["hashes[]"]; len(got) != 2 {
+			t.Errorf("hashes[] = %v, want 2 hashes", got)
+		}
+		writeJSON(w, map[string]any{"data": []any{"ABC"}})
+	})
+	got := g.IsAvailable([]string{"abc", "def", ""})
+	if !got["abc"] || got["def"] {
+		t.Errorf("IsAvailable = %v, want only abc", got)
+	}
+	if _, ok := got["def"]; !ok {
+		t.Errorf("IsAvailable = %v, want an answer for def", got)
+	}
+}
+
+func TestIsAvailableFailure(t *testing.T) {
+	g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if got := g.IsAvailable([]string{"abc"}); len(got) != 0 {
+		t.Errorf("IsAvailable = %v, want no answers when the check fails", got)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   *request.HTTPError
+	}{
+		{"unauthorized", http.StatusUnauthorized, `{}`, request.AuthInvalidError},
+		{"forbidden", http.StatusForbidden, `{}`, request.AuthInvalidError},
+		{"rate limited", http.StatusTooManyRequests, `{}`, request.RateLimitedError},
+		{"legal", http.StatusUnavailableForLegalReasons, `{}`, request.InfringingFileError},
+		{"unavailable", http.StatusServiceUnavailable, `{}`, request.ServiceUnavailableError},
+		{"bad gateway", http.StatusBadGateway, `{}`, request.ServiceUnavailableError},
+		{"bad request", http.StatusBadRequest, `{}`, request.UnknownError},
+		{"error message", http.StatusOK, `{"error": "something broke"}`, request.UnknownError},
+		{"missing torrent", http.StatusOK, `{"data": null}`, request.TorrentNotFoundError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGeneric(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			})
+			_, err := g.GetTorrent("1")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("GetTorrent error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
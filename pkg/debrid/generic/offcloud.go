@@ -0,0 +1,60 @@
+package generic
+
+import (
+	"net/http"
+
+	"github.com/sirrobot01/decypharr/internal/config"
+)
+
+// Offcloud is the built-in mapping for offcloud.com, used when a debrid is named "offcloud" without a mapping
+var Offcloud = config.DebridMapping{
+	Host: "https://offcloud.com/api",
+	Auth: config.DebridMappingAuth{Query: "key"},
+	AddMagnet: &config.DebridEndpoint{
+		Method: http.MethodPost,
+		URL:    "/cloud",
+		Form:   map[string]string{"url": "{magnet}"},
+	},
+	Status: &config.DebridEndpoint{
+		Method: http.MethodPost,
+		URL:    "/cloud/status",
+		Form:   map[string]string{"requestId": "{id}"},
+		Result: "status",
+	},
+	List: &config.DebridEndpoint{
+		URL: "/cloud/history",
+	},
+	Delete: &config.DebridEndpoint{
+		URL: "/cloud/remove/{id}",
+	},
+	Files: &config.DebridEndpoint{
+		URL: "/cloud/explore/{id}",
+	},
+	Availability: &config.DebridEndpoint{
+		Method: http.MethodPost,
+		URL:    "/cache",
+		Form:   map[string]string{"hashes[]": "{hashes}"},
+		Result: "cachedItems",
+	},
+	Error: "error",
+	TorrentFields: map[string]string{
+		"id":     "requestId",
+		"name":   "fileName",
+		"magnet": "originalLink",
+		"status": "status",
+		"size":   "fileSize",
+		"added":  "createdOn",
+		"link":   "url",
+	},
+	FileFields: map[string]string{
+		"link": "$", // explore returns a list of links
+	},
+	StatusMap: map[string]string{
+		"created":     "downloading",
+		"queued":      "downloading",
+		"downloading": "downloading",
+		"downloaded":  "downloaded",
+		"error":       "error",
+		"canceled":    "error",
+	},
+}
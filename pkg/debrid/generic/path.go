@@ -0,0 +1,134 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// extract walks a decoded JSON value following a JSONPath-like expression,
+// e.g. "data.magnets[0].id". An empty path or "$" returns the value itself.
+func extract(data any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return data, data != nil
+	}
+	current := data
+	for _, segment := range strings.Split(path, ".") {
+		key, indexes, err := parseSegment(segment)
+		if err != nil {
+			return nil, false
+		}
+		if key != "" {
+			obj, ok := current.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[key]; !ok {
+				return nil, false
+			}
+		}
+		for _, idx := range indexes {
+			arr, ok := current.([]any)
+			if !ok {
+				return nil, false
+			}
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			current = arr[idx]
+		}
+	}
+	return current, current != nil
+}
+
+// parseSegment splits "files[0][1]" into the key and its indexes
+func parseSegment(segment string) (string, []int, error) {
+	open := strings.Index(segment, "[")
+	if open == -1 {
+		return segment, nil, nil
+	}
+	key := segment[:open]
+	var indexes []int
+	rest := segment[open:]
+	for rest != "" {
+		if rest[0] != '[' {
+			return "", nil, fmt.Errorf("invalid path segment: %s", segment)
+		}
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return "", nil, fmt.Errorf("invalid path segment: %s", segment)
+		}
+		idx, err := strconv.Atoi(rest[1:end])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid index in path segment: %s", segment)
+		}
+		indexes = append(indexes, idx)
+		rest = rest[end+1:]
+	}
+	return key, indexes, nil
+}
+
+func extractString(data any, path string) string {
+	if path == "" {
+		return ""
+	}
+	v, ok := extract(data, path)
+	if !ok {
+		return ""
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return ""
+	}
+}
+
+func extractInt(data any, path string) int64 {
+	s := extractString(data, path)
+	if s == "" {
+		return 0
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return int64(f)
+}
+
+func extractFloat(data any, path string) float64 {
+	f, _ := strconv.ParseFloat(extractString(data, path), 64)
+	return f
+}
+
+// extractTime reads a unix timestamp or an RFC3339 date
+func extractTime(data any, path string) (time.Time, bool) {
+	s := extractString(data, path)
+	if s == "" {
+		return time.Time{}, false
+	}
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+func extractList(data any, path string) []any {
+	v, ok := extract(data, path)
+	if !ok {
+		return nil
+	}
+	list, _ := v.([]any)
+	return list
+}
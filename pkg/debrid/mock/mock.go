@@ -0,0 +1,390 @@
+package mock
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+	"math/rand/v2"
+	"net/http"
+	gourl "net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mock is an in-memory debrid. Torrents live as long as the process, and their files are
+// served by a local HTTP server with generated content.
+type Mock struct {
+	Name             string
+	DownloadUncached bool
+
+	MountPath   string
+	logger      zerolog.Logger
+	checkCached bool
+
+	cachedHashes          []string
+	downloadTime          time.Duration
+	files                 int
+	fileSize              int64
+	hosterUnavailableRate float64
+	trafficExceededRate   float64
+
+	mu       sync.RWMutex
+	torrents map[string]*torrent
+	nextId   atomic.Int64
+	baseURL  string
+	server   *http.Server
+}
+
+type torrent struct {
+	id     string
+	hash   string
+	name   string
+	added  time.Time
+	cached bool
+	files  []file
+}
+
+type file struct {
+	id   string
+	name string
+	size int64
+	seed byte
+}
+
+func (t *torrent) file(id string) *file {
+	for i := range t.files {
+		if t.files[i].id == id {
+			return &t.files[i]
+		}
+	}
+	return nil
+}
+
+// New creates the mock debrid. Options are read from dc.Mock, opts are ignored since no requests are made.
+func New(dc config.Debrid, opts ...request.ClientOption) *Mock {
+	mc := config.MockDebrid{}
+	if dc.Mock != nil {
+		mc = *dc.Mock
+	}
+	downloadTime, err := time.ParseDuration(mc.DownloadTime)
+	if err != nil {
+		downloadTime = 30 * time.Second
+	}
+	fileSize, _ := config.ParseSize(mc.FileSize)
+	if fileSize <= 0 {
+		fileSize = 10 * 1024 * 1024
+	}
+
+	m := &Mock{
+		Name:                  "mock",
+		DownloadUncached:      dc.DownloadUncached,
+		MountPath:             dc.Folder,
+		logger:                logger.New(dc.Name),
+		checkCached:           dc.CheckCached,
+		cachedHashes:          mc.CachedHashes,
+		downloadTime:          downloadTime,
+		files:                 max(mc.Files, 1),
+		fileSize:              fileSize,
+		hosterUnavailableRate: mc.HosterUnavailableRate,
+		trafficExceededRate:   mc.TrafficExceededRate,
+		torrents:              make(map[string]*torrent),
+	}
+	if err := m.startServer(); err != nil {
+		m.logger.Error().Err(err).Msg("Download links will not work")
+	}
+	return m
+}
+
+func (m *Mock) isCached(hash string) bool {
+	return slices.ContainsFunc(m.cachedHashes, func(h string) bool {
+		return h == "*" || strings.EqualFold(h, hash)
+	})
+}
+
+// status returns the simulated status and progress of a torrent
+func (m *Mock) status(t *torrent) (string, float64) {
+	if t.cached {
+		return "downloaded", 100
+	}
+	elapsed := time.Since(t.added)
+	if elapsed >= m.downloadTime {
+		return "downloaded", 100
+	}
+	return "downloading", float64(elapsed) / float64(m.downloadTime) * 100
+}
+
+func (m *Mock) link(t *torrent, f *file) string {
+	return fmt.Sprintf("%s/%s/%s/%s", m.baseURL, t.id, f.id, gourl.PathEscape(f.name))
+}
+
+func (m *Mock) toTorrent(t *torrent) *types.Torrent {
+	status, progress := m.status(t)
+	result := &types.Torrent{
+		Id:               t.id,
+		InfoHash:         t.hash,
+		Name:             t.name,
+		Folder:           t.name,
+		Filename:         t.name,
+		OriginalFilename: t.name,
+		Status:           status,
+		Progress:         progress,
+		Files:            make(map[string]types.File),
+		Added:            t.added.Format(time.RFC3339),
+		MountPath:        m.MountPath,
+		Debrid:           m.Name,
+	}
+	for _, f := range t.files {
+		result.Size += f.size
+		result.Bytes += f.size
+	}
+	if status != "downloaded" {
+		result.Speed = result.Size / int64(max(m.downloadTime.Seconds(), 1))
+		result.Seeders = 10
+		return result
+	}
+	for i := range t.files {
+		f := &t.files[i]
+		link := m.link(t, f)
+		result.Files[f.name] = types.File{
+			TorrentId: t.id,
+			Id:        f.id,
+			Name:      f.name,
+			Size:      f.size,
+			Path:      f.name,
+			Link:      link,
+			Generated: time.Now(),
+		}
+	}
+	return result
+}
+
+func (m *Mock) get(torrentId string) (*torrent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.torrents[torrentId]
+	if !ok {
+		return nil, request.TorrentNotFoundError
+	}
+	return t, nil
+}
+
+func (m *Mock) GetName() string {
+	return m.Name
+}
+
+func (m *Mock) GetLogger() zerolog.Logger {
+	return m.logger
+}
+
+func (m *Mock) IsAvailable(hashes []string) map[string]bool {
+	result := make(map[string]bool)
+	for _, h := range hashes {
+		if h != "" {
+			result[h] = m.isCached(h)
+		}
+	}
+	return result
+}
+
+func (m *Mock) SubmitMagnet(tr *types.Torrent) (*types.Torrent, error) {
+	hash := tr.InfoHash
+	name := tr.Name
+	if tr.Magnet != nil {
+		hash = cmp.Or(hash, tr.Magnet.InfoHash)
+		name = cmp.Or(name, tr.Magnet.Name)
+	}
+	name = utils.RemoveInvalidChars(cmp.Or(name, hash, "mock"))
+
+	t := &torrent{
+		id:     strconv.FormatInt(m.nextId.Add(1), 10),
+		hash:   hash,
+		name:   name,
+		added:  time.Now(),
+		cached: m.isCached(hash),
+	}
+	for i := 0; i < m.files; i++ {
+		fileName := name + ".mkv"
+		if m.files > 1 {
+			fileName = fmt.Sprintf("%s - part %d.mkv", name, i+1)
+		}
+		t.files = append(t.files, file{
+			id:   strconv.Itoa(i),
+			name: fileName,
+			size: m.fileSize,
+			seed: byte(i),
+		})
+	}
+
+	m.mu.Lock()
+	m.torrents[t.id] = t
+	m.mu.Unlock()
+
+	tr.Id = t.id
+	tr.MountPath = m.MountPath
+	tr.Debrid = m.Name
+	return tr, nil
+}
+
+func (m *Mock) GetTorrent(torrentId string) (*types.Torrent, error) {
+	t, err := m.get(torrentId)
+	if err != nil {
+		return nil, err
+	}
+	return m.toTorrent(t), nil
+}
+
+func (m *Mock) UpdateTorrent(tr *types.Torrent) error {
+	t, err := m.get(tr.Id)
+	if err != nil {
+		return err
+	}
+	updated := m.toTorrent(t)
+	tr.InfoHash = updated.InfoHash
+	tr.Name = updated.Name
+	tr.Folder = updated.Folder
+	tr.Filename = updated.Filename
+	tr.OriginalFilename = updated.OriginalFilename
+	tr.Status = updated.Status
+	tr.Progress = updated.Progress
+	tr.Speed = updated.Speed
+	tr.Seeders = updated.Seeders
+	tr.Size = updated.Size
+	tr.Bytes = updated.Bytes
+	tr.Added = updated.Added
+	tr.Files = updated.Files
+	tr.MountPath = m.MountPath
+	tr.Debrid = m.Name
+	return nil
+}
+
+func (m *Mock) CheckStatus(torrent *types.Torrent, isSymlink bool) (*types.Torrent, error) {
+	for {
+		err := m.UpdateTorrent(torrent)
+		if err != nil || torrent == nil {
+			return torrent, err
+		}
+		status := torrent.Status
+		if status == "downloaded" {
+			m.logger.Info().Msgf("Torrent: %s downloaded", torrent.Name)
+			if !isSymlink {
+				if err = m.GenerateDownloadLinks(torrent); err != nil {
+					return torrent, err
+				}
+			}
+			break
+		} else if utils.Contains(m.GetDownloadingStatus(), status) {
+			if !torrent.DownloadUncached {
+				return torrent, fmt.Errorf("torrent: %s not cached", torrent.Name)
+			}
+			// Break out of the loop if the torrent is downloading.
+			// This is necessary to prevent infinite loop since we moved to sync downloading and async processing
+			return torrent, nil
+		} else {
+			return torrent, fmt.Errorf("torrent: %s has error", torrent.Name)
+		}
+
+	}
+	return torrent, nil
+}
+
+func (m *Mock) DeleteTorrent(torrentId string) error {
+	m.mu.Lock()
+	delete(m.torrents, torrentId)
+	m.mu.Unlock()
+	m.logger.Info().Msgf("Torrent: %s deleted from mock", torrentId)
+	return nil
+}
+
+func (m *Mock) GenerateDownloadLinks(t *types.Torrent) error {
+	for name, f := range t.Files {
+		link, err := m.GetDownloadLink(t, &f)
+		if err != nil {
+			return err
+		}
+		f.DownloadLink = link
+		t.Files[name] = f
+	}
+	return nil
+}
+
+// GetDownloadLink returns a link to the local file server, failing at the configured rates
+func (m *Mock) GetDownloadLink(t *types.Torrent, f *types.File) (*types.DownloadLink, error) {
+	if _, err := m.get(t.Id); err != nil {
+		return nil, request.ErrLinkBroken
+	}
+	r := rand.Float64()
+	if r < m.hosterUnavailableRate {
+		return nil, request.HosterUnavailableError
+	}
+	if r < m.hosterUnavailableRate+m.trafficExceededRate {
+		return nil, request.TrafficExceededError
+	}
+	return &types.DownloadLink{
+		Filename:     f.Name,
+		Link:         f.Link,
+		DownloadLink: f.Link,
+		Size:         f.Size,
+		Id:           t.Id + "-" + f.Id,
+		Generated:    time.Now(),
+		AccountId:    "0",
+	}, nil
+}
+
+func (m *Mock) GetTorrents() ([]*types.Torrent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	torrents := make([]*types.Torrent, 0, len(m.torrents))
+	for _, t := range m.torrents {
+		torrents = append(torrents, m.toTorrent(t))
+	}
+	return torrents, nil
+}
+
+func (m *Mock) GetDownloads() (map[string]types.DownloadLink, error) {
+	return nil, nil
+}
+
+func (m *Mock) GetDownloadingStatus() []string {
+	return []string{"downloading"}
+}
+
+func (m *Mock) GetCheckCached() bool {
+	return m.checkCached
+}
+
+func (m *Mock) GetDownloadUncached() bool {
+	return m.DownloadUncached
+}
+
+// CheckLink reports links to deleted torrents as broken
+func (m *Mock) CheckLink(link string) error {
+	path := strings.TrimPrefix(link, m.baseURL+"/")
+	torrentId, _, _ := strings.Cut(path, "/")
+	if _, err := m.get(torrentId); err != nil {
+		return request.ErrLinkBroken
+	}
+	return nil
+}
+
+func (m *Mock) GetMountPath() string {
+	return m.MountPath
+}
+
+func (m *Mock) DisableAccount(accountId string) {
+}
+
+func (m *Mock) ResetActiveDownloadKeys() {
+}
+
+func (m *Mock) DeleteDownloadLink(linkId string) error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// content is a deterministic, seekable file of the given size.
+// Byte i of a file is always the same, so range requests can be checked against each other.
+type content struct {
+	size   int64
+	offset int64
+	seed   byte
+}
+
+func (c *content) Read(p []byte) (int, error) {
+	if c.offset >= c.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), c.size-c.offset))
+	for i := 0; i < n; i++ {
+		pos := c.offset + int64(i)
+		p[i] = byte(pos) ^ byte(pos>>8) ^ c.seed
+	}
+	c.offset += int64(n)
+	return n, nil
+}
+
+func (c *content) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = c.offset + offset
+	case io.SeekEnd:
+		abs = c.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	c.offset = abs
+	return abs, nil
+}
+
+// startServer serves the files of downloaded torrents at /<torrent id>/<file id>/<name>
+func (m *Mock) startServer() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start mock file server: %w", err)
+	}
+	m.baseURL = "http://" + listener.Addr().String()
+	m.server = &http.Server{Handler: http.HandlerFunc(m.serveFile)}
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.logger.Error().Err(err).Msg("Mock file server stopped")
+		}
+	}()
+	return nil
+}
+
+// Close stops the file server, the engine closes its clients when it's reset
+func (m *Mock) Close() error {
+	if m.server == nil {
+		return nil
+	}
+	return m.server.Close()
+}
+
+func (m *Mock) serveFile(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 3 {
+		http.NotFound(w, r)
+		return
+	}
+	m.mu.RLock()
+	t, ok := m.torrents[parts[0]]
+	m.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	f := t.file(parts[1])
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, f.name, time.Time{}, &content{size: f.size, seed: f.seed})
+}
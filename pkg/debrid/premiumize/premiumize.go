@@ -0,0 +1,457 @@
+package premiumize
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/types"
+	"io"
+	"net/http"
+	gourl "net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Premiumize struct {
+	Name             string
+	Host             string `json:"host"`
+	APIKey           string
+	DownloadUncached bool
+	client           *request.Client
+
+	MountPath   string
+	logger      zerolog.Logger
+	checkCached bool
+	addSamples  bool
+}
+
+func New(dc config.Debrid, opts ...request.ClientOption) *Premiumize {
+	rl := request.ParseRateLimit(dc.RateLimit)
+
+	_log := logger.New(dc.Name)
+	client := request.New(append([]request.ClientOption{
+		request.WithLogger(_log),
+		request.WithRateLimiter(rl),
+		request.WithProxy(dc.Proxy),
+	}, opts...)...)
+
+	return &Premiumize{
+		Name:             "premiumize",
+		Host:             "https://www.premiumize.me/api",
+		APIKey:           dc.APIKey,
+		DownloadUncached: dc.DownloadUncached,
+		client:           client,
+		MountPath:        dc.Folder,
+		logger:           _log,
+		checkCached:      dc.CheckCached,
+		addSamples:       dc.AddSamples,
+	}
+}
+
+// newRequest builds an authenticated request. Premiumize takes the API key as a query parameter,
+// form values are sent url-encoded in the body.
+func (pm *Premiumize) newRequest(method, path string, query, form gourl.Values) *http.Request {
+	if query == nil {
+		query = gourl.Values{}
+	}
+	query.Set("apikey", pm.APIKey)
+	url := fmt.Sprintf("%s%s?%s", pm.Host, path, query.Encode())
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, _ := http.NewRequest(method, url, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return req
+}
+
+// do sends the request and decodes the response into v, turning API errors into Go errors
+func (pm *Premiumize) do(req *http.Request, v any) error {
+	resp, err := pm.client.MakeRequest(req)
+	if err != nil {
+		return err
+	}
+	var base baseResponse
+	if err := json.Unmarshal(resp, &base); err != nil {
+		return err
+	}
+	if base.Status == "error" {
+		return apiError(base.Message)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(resp, v)
+}
+
+// apiError maps a Premiumize error message to a request error kind. Premiumize has no error codes.
+func apiError(message string) error {
+	msg := strings.ToLower(message)
+	kind := request.UnknownError
+	switch {
+	case strings.Contains(msg, "not logged in"), strings.Contains(msg, "apikey"), strings.Contains(msg, "premium"):
+		kind = request.AuthInvalidError
+	case strings.Contains(msg, "fair use"), strings.Contains(msg, "limit reached"):
+		kind = request.TrafficExceededError
+	case strings.Contains(msg, "active transfers"), strings.Contains(msg, "too many transfers"):
+		kind = request.TooManyActiveDownloadsError
+	case strings.Contains(msg, "not found"):
+		kind = request.TorrentNotFoundError
+	}
+	return request.ProviderError(kind, "premiumize error: %s", message)
+}
+
+func (pm *Premiumize) GetName() string {
+	return pm.Name
+}
+
+func (pm *Premiumize) GetLogger() zerolog.Logger {
+	return pm.logger
+}
+
+func (pm *Premiumize) IsAvailable(hashes []string) map[string]bool {
+	// Check if the infohashes are available in the local cache
+	result := make(map[string]bool)
+
+	// Divide hashes into groups of 100
+	for i := 0; i < len(hashes); i += 100 {
+		end := min(i+100, len(hashes))
+
+		query := gourl.Values{}
+		validHashes := make([]string, 0, end-i)
+		for _, hash := range hashes[i:end] {
+			if hash != "" {
+				validHashes = append(validHashes, hash)
+				query.Add("items[]", hash)
+			}
+		}
+
+		// If no valid hashes in this batch, continue to the next batch
+		if len(validHashes) == 0 {
+			continue
+		}
+
+		var data cacheCheckResponse
+		if err := pm.do(pm.newRequest(http.MethodGet, "/cache/check", query, nil), &data); err != nil {
+			pm.logger.Info().Msgf("Error checking availability: %v", err)
+			return result
+		}
+		// Results are returned in the same order as the items
+		for idx, cached := range data.Response {
+			if idx < len(validHashes) {
+				result[validHashes[idx]] = cached
+			}
+		}
+	}
+	return result
+}
+
+func (pm *Premiumize) SubmitMagnet(torrent *types.Torrent) (*types.Torrent, error) {
+	form := gourl.Values{}
+	form.Set("src", torrent.Magnet.Link)
+	var data createTransferResponse
+	if err := pm.do(pm.newRequest(http.MethodPost, "/transfer/create", nil, form), &data); err != nil {
+		return nil, err
+	}
+	if data.ID == "" {
+		return nil, fmt.Errorf("error adding torrent")
+	}
+	torrent.Id = data.ID
+	torrent.Debrid = pm.Name
+	torrent.MountPath = pm.MountPath
+	return torrent, nil
+}
+
+func getPremiumizeStatus(status string) string {
+	switch status {
+	case "finished", "seeding":
+		return "downloaded"
+	case "waiting", "queued", "running":
+		return "downloading"
+	default:
+		return "error"
+	}
+}
+
+// getTransfer looks up a single transfer. Premiumize has no endpoint for this, so the list is searched.
+func (pm *Premiumize) getTransfer(torrentId string) (*transfer, error) {
+	transfers, err := pm.getTransfers()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transfers {
+		if t.ID == torrentId {
+			return &t, nil
+		}
+	}
+	return nil, request.TorrentNotFoundError
+}
+
+func (pm *Premiumize) getTransfers() ([]transfer, error) {
+	var data transferListResponse
+	if err := pm.do(pm.newRequest(http.MethodGet, "/transfer/list", nil, nil), &data); err != nil {
+		return nil, err
+	}
+	return data.Transfers, nil
+}
+
+// updateFromTransfer copies the transfer's state to the torrent, listing its files once it is finished
+func (pm *Premiumize) updateFromTransfer(t *types.Torrent, tr *transfer) error {
+	status := getPremiumizeStatus(tr.Status)
+	name := utils.RemoveInvalidChars(tr.Name)
+	t.Id = tr.ID
+	t.Name = name
+	t.Status = status
+	t.Filename = name
+	t.OriginalFilename = name
+	t.Folder = name
+	t.MountPath = pm.MountPath
+	t.Debrid = pm.Name
+	if t.InfoHash == "" {
+		t.InfoHash = utils.ExtractInfoHash(tr.Src)
+	}
+	if status != "downloaded" {
+		t.Progress = tr.Progress * 100
+		return nil
+	}
+	t.Progress = 100
+
+	var items []item
+	var err error
+	switch {
+	case tr.FolderID != "":
+		items, err = pm.listFolder(tr.FolderID, "")
+	case tr.FileID != "":
+		var details itemDetailsResponse
+		err = pm.do(pm.newRequest(http.MethodGet, "/item/details", gourl.Values{"id": {tr.FileID}}, nil), &details)
+		items = []item{details.item}
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	files := make(map[string]types.File)
+	var bytes int64
+	var added int64
+	for _, f := range items {
+		fileName := filepath.Base(f.Name)
+		if !pm.addSamples && utils.IsSampleFile(f.Name) {
+			continue
+		}
+		if !cfg.IsAllowedFile(fileName) || !cfg.IsSizeAllowed(f.Size) {
+			continue
+		}
+		file := types.File{
+			TorrentId: t.Id,
+			Id:        f.ID,
+			Name:      fileName,
+			Size:      f.Size,
+			Path:      f.Name,
+			Link:      f.Link,
+			DownloadLink: &types.DownloadLink{
+				Filename:     fileName,
+				Link:         f.Link,
+				DownloadLink: f.Link,
+				Size:         f.Size,
+				Id:           f.ID,
+				Generated:    time.Now(),
+				AccountId:    "0",
+			},
+			Generated: time.Now(),
+		}
+		if _, ok := files[file.Name]; ok {
+			// File already exists, use path as key
+			files[file.Path] = file
+		} else {
+			files[file.Name] = file
+		}
+		bytes += f.Size
+		if added == 0 || f.CreatedAt < added {
+			added = f.CreatedAt
+		}
+	}
+	t.Files = files
+	t.Bytes = bytes
+	if added > 0 {
+		t.Added = time.Unix(added, 0).Format(time.RFC3339)
+	}
+	return nil
+}
+
+// listFolder returns every file below a folder, with its path relative to the folder
+func (pm *Premiumize) listFolder(folderId, parentPath string) ([]item, error) {
+	var data folderListResponse
+	if err := pm.do(pm.newRequest(http.MethodGet, "/folder/list", gourl.Values{"id": {folderId}}, nil), &data); err != nil {
+		return nil, err
+	}
+	items := make([]item, 0, len(data.Content))
+	for _, it := range data.Content {
+		currentPath := it.Name
+		if parentPath != "" {
+			currentPath = filepath.Join(parentPath, it.Name)
+		}
+		if it.Type == "folder" {
+			sub, err := pm.listFolder(it.ID, currentPath)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, sub...)
+			continue
+		}
+		it.Name = currentPath
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func (pm *Premiumize) GetTorrent(torrentId string) (*types.Torrent, error) {
+	tr, err := pm.getTransfer(torrentId)
+	if err != nil {
+		return nil, err
+	}
+	t := &types.Torrent{
+		Files: make(map[string]types.File),
+	}
+	if err := pm.updateFromTransfer(t, tr); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (pm *Premiumize) UpdateTorrent(t *types.Torrent) error {
+	tr, err := pm.getTransfer(t.Id)
+	if err != nil {
+		return err
+	}
+	return pm.updateFromTransfer(t, tr)
+}
+
+func (pm *Premiumize) CheckStatus(torrent *types.Torrent, isSymlink bool) (*types.Torrent, error) {
+	for {
+		err := pm.UpdateTorrent(torrent)
+		if err != nil || torrent == nil {
+			return torrent, err
+		}
+		status := torrent.Status
+		if status == "downloaded" {
+			pm.logger.Info().Msgf("Torrent: %s downloaded", torrent.Name)
+			break
+		} else if utils.Contains(pm.GetDownloadingStatus(), status) {
+			if !torrent.DownloadUncached {
+				return torrent, fmt.Errorf("torrent: %s not cached", torrent.Name)
+			}
+			// Break out of the loop if the torrent is downloading.
+			// This is necessary to prevent infinite loop since we moved to sync downloading and async processing
+			return torrent, nil
+		} else {
+			return torrent, fmt.Errorf("torrent: %s has error", torrent.Name)
+		}
+
+	}
+	return torrent, nil
+}
+
+func (pm *Premiumize) DeleteTorrent(torrentId string) error {
+	form := gourl.Values{}
+	form.Set("id", torrentId)
+	if err := pm.do(pm.newRequest(http.MethodPost, "/transfer/delete", nil, form), nil); err != nil {
+		return err
+	}
+	pm.logger.Info().Msgf("Torrent: %s deleted from Premiumize", torrentId)
+	return nil
+}
+
+func (pm *Premiumize) GenerateDownloadLinks(t *types.Torrent) error {
+	// Download links are returned with the file listing
+	return nil
+}
+
+func (pm *Premiumize) GetDownloadLink(t *types.Torrent, file *types.File) (*types.DownloadLink, error) {
+	var details itemDetailsResponse
+	if err := pm.do(pm.newRequest(http.MethodGet, "/item/details", gourl.Values{"id": {file.Id}}, nil), &details); err != nil {
+		return nil, err
+	}
+	if details.Link == "" {
+		return nil, request.ErrLinkBroken
+	}
+	return &types.DownloadLink{
+		Filename:     file.Name,
+		Link:         file.Link,
+		DownloadLink: details.Link,
+		Size:         file.Size,
+		Id:           file.Id,
+		Generated:    time.Now(),
+		AccountId:    "0",
+	}, nil
+}
+
+func (pm *Premiumize) GetTorrents() ([]*types.Torrent, error) {
+	torrents := make([]*types.Torrent, 0)
+	transfers, err := pm.getTransfers()
+	if err != nil {
+		return torrents, err
+	}
+	for _, tr := range transfers {
+		status := getPremiumizeStatus(tr.Status)
+		if status != "downloaded" {
+			continue
+		}
+		name := utils.RemoveInvalidChars(tr.Name)
+		torrents = append(torrents, &types.Torrent{
+			Id:               tr.ID,
+			Name:             name,
+			Status:           status,
+			Progress:         100,
+			Filename:         name,
+			OriginalFilename: name,
+			Files:            make(map[string]types.File),
+			InfoHash:         utils.ExtractInfoHash(tr.Src),
+			Debrid:           pm.Name,
+			MountPath:        pm.MountPath,
+		})
+	}
+	return torrents, nil
+}
+
+func (pm *Premiumize) GetDownloads() (map[string]types.DownloadLink, error) {
+	return nil, nil
+}
+
+func (pm *Premiumize) GetDownloadingStatus() []string {
+	return []string{"downloading"}
+}
+
+func (pm *Premiumize) GetCheckCached() bool {
+	return pm.checkCached
+}
+
+func (pm *Premiumize) GetDownloadUncached() bool {
+	return pm.DownloadUncached
+}
+
+func (pm *Premiumize) CheckLink(link string) error {
+	return nil
+}
+
+func (pm *Premiumize) GetMountPath() string {
+	return pm.MountPath
+}
+
+func (pm *Premiumize) DisableAccount(accountId string) {
+}
+
+func (pm *Premiumize) ResetActiveDownloadKeys() {
+}
+
+func (pm *Premiumize) DeleteDownloadLink(linkId string) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package premiumize
+
+type baseResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type cacheCheckResponse struct {
+	baseResponse
+	Response []bool   `json:"response"`
+	Filename []string `json:"filename"`
+}
+
+type createTransferResponse struct {
+	baseResponse
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type transfer struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Message  string  `json:"message"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress"`
+	Src      string  `json:"src"`
+	FolderID string  `json:"folder_id"`
+	FileID   string  `json:"file_id"`
+}
+
+type transferListResponse struct {
+	baseResponse
+	Transfers []transfer `json:"transfers"`
+}
+
+type item struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"` // file or folder
+	Size       int64  `json:"size"`
+	CreatedAt  int64  `json:"created_at"`
+	Link       string `json:"link"`
+	StreamLink string `json:"stream_link"`
+}
+
+type folderListResponse struct {
+	baseResponse
+	Content  []item `json:"content"`
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id"`
+	FolderID string `json:"folder_id"`
+}
+
+type itemDetailsResponse struct {
+	baseResponse
+	item
+}
@@ -0,0 +1,68 @@
+package realdebrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+// errorKinds maps Real Debrid error codes, see https://api.real-debrid.com/#api_error_codes
+var errorKinds = map[int]*request.HTTPError{
+	5:  request.RateLimitedError, // Slow down
+	7:  request.TorrentNotFoundError,
+	8:  request.AuthInvalidError, // Bad token
+	9:  request.AuthInvalidError, // Permission denied
+	12: request.AuthInvalidError,
+	13: request.AuthInvalidError,
+	14: request.AuthInvalidError,        // Account locked
+	15: request.AuthInvalidError,        // Account not activated
+	16: request.UnknownError,            // Hoster unsupported
+	17: request.ServiceUnavailableError, // Hoster in maintenance
+	18: request.TrafficExceededError,    // Hoster limit reached
+	19: request.HosterUnavailableError,  // File has been removed
+	20: request.UnknownError,            // Hoster not available for free users
+	21: request.TooManyActiveDownloadsError,
+	22: request.AuthInvalidError, // IP address not allowed
+	23: request.TrafficExceededError,
+	24: request.HosterUnavailableError,  // Link has been nerfed
+	25: request.ServiceUnavailableError, // Service unavailable
+	34: request.RateLimitedError,
+	35: request.InfringingFileError,
+	36: request.TrafficExceededError, // Fair usage limit
+}
+
+// apiError maps a failed Real Debrid response to a request error kind
+func apiError(statusCode int, body []byte) error {
+	var data ErrorResponse
+	if err := json.Unmarshal(body, &data); err != nil || data.ErrorCode == 0 {
+		if statusCode == http.StatusNotFound {
+			return request.TorrentNotFoundError
+		}
+		return request.ProviderError(request.StatusError(statusCode), "realdebrid API error: Status: %d || Body: %s", statusCode, string(body))
+	}
+	kind, ok := errorKinds[data.ErrorCode]
+	if !ok {
+		kind = request.UnknownError
+	}
+	return request.ProviderError(kind, "realdebrid API error: Status: %d || Code: %d || %s", statusCode, data.ErrorCode, data.Error)
+}
+
+// makeRequest is request.Client.MakeRequest, with Real Debrid error codes mapped
+func makeRequest(client *request.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, apiError(resp.StatusCode, body)
+	}
+	return body, nil
+}
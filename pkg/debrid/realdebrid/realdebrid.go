@@ -3,19 +3,18 @@ package realdebrid
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/sirrobot01/decypharr/internal/config"
 	"github.com/sirrobot01/decypharr/internal/logger"
 	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/account"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"io"
 	"net/http"
 	gourl "net/url"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,10 +25,12 @@ type RealDebrid struct {
 	Name string
 	Host string `json:"host"`
 
-	APIKey             string
-	currentDownloadKey string
-	accounts           map[string]types.Account
-	accountsMutex      sync.RWMutex
+	APIKey   string
+	accounts *account.Manager
+	// linkAccounts maps download link ids to the token of the account that created them
+	linkAccounts sync.Map
+	// pending holds the ids of the torrents last seen downloading, GetNewTorrents pages until it sees them again
+	pending sync.Map
 
 	DownloadUncached bool
 	client           *request.Client
@@ -39,9 +40,11 @@ type RealDebrid struct {
 	logger      zerolog.Logger
 	checkCached bool
 	addSamples  bool
+
+	fileSelection *config.FileSelection
 }
 
-func New(dc config.Debrid) *RealDebrid {
+func New(dc config.Debrid, opts ...request.ClientOption) *RealDebrid {
 	rl := request.ParseRateLimit(dc.RateLimit)
 
 	headers := map[string]string{
@@ -49,47 +52,33 @@ func New(dc config.Debrid) *RealDebrid {
 	}
 	_log := logger.New(dc.Name)
 
-	accounts := make(map[string]types.Account)
-	currentDownloadKey := dc.DownloadAPIKeys[0]
-	for idx, key := range dc.DownloadAPIKeys {
-		id := strconv.Itoa(idx)
-		accounts[id] = types.Account{
-			Name:  key,
-			ID:    id,
-			Token: key,
-		}
-	}
-
-	downloadHeaders := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", currentDownloadKey),
-	}
-
 	return &RealDebrid{
 		Name:             "realdebrid",
 		Host:             "https://api.real-debrid.com/rest/1.0",
 		APIKey:           dc.APIKey,
-		accounts:         accounts,
+		accounts:         account.NewManager(dc.Name, dc.DownloadAPIKeys),
 		DownloadUncached: dc.DownloadUncached,
-		client: request.New(
+		client: request.New(append([]request.ClientOption{
 			request.WithHeaders(headers),
 			request.WithRateLimiter(rl),
 			request.WithLogger(_log),
 			request.WithMaxRetries(5),
 			request.WithRetryableStatus(429, 502),
 			request.WithProxy(dc.Proxy),
-		),
-		downloadClient: request.New(
-			request.WithHeaders(downloadHeaders),
+		}, opts...)...),
+		// The Authorization header is set per request, with the key of the picked account
+		downloadClient: request.New(append([]request.ClientOption{
+			request.WithRateLimiter(rl),
 			request.WithLogger(_log),
 			request.WithMaxRetries(10),
 			request.WithRetryableStatus(429, 447, 502),
 			request.WithProxy(dc.Proxy),
-		),
-		currentDownloadKey: currentDownloadKey,
-		MountPath:          dc.Folder,
-		logger:             logger.New(dc.Name),
-		checkCached:        dc.CheckCached,
-		addSamples:         dc.AddSamples,
+		}, opts...)...),
+		MountPath:     dc.Folder,
+		logger:        logger.New(dc.Name),
+		checkCached:   dc.CheckCached,
+		addSamples:    dc.AddSamples,
+		fileSelection: dc.FileSelection,
 	}
 }
 
@@ -131,21 +120,30 @@ func getSelectedFiles(t *types.Torrent, data torrentInfo) map[string]types.File
 // validate is used to determine if the files should be validated
 // if validate is false, selected files will be returned
 func (r *RealDebrid) getTorrentFiles(t *types.Torrent, data torrentInfo) map[string]types.File {
+	files, _ := r.filterTorrentFiles(t, data)
+	return files
+}
+
+// filterTorrentFiles is getTorrentFiles, also returning the files that were left out and why
+func (r *RealDebrid) filterTorrentFiles(t *types.Torrent, data torrentInfo) (map[string]types.File, []types.SkippedFile) {
 	files := make(map[string]types.File)
+	var skipped []types.SkippedFile
 	cfg := config.Get()
 	idx := 0
 
 	for _, f := range data.Files {
 		name := filepath.Base(f.Path)
+		reason := ""
 		if !r.addSamples && utils.IsSampleFile(f.Path) {
 			// Skip sample files
-			continue
-		}
-
-		if !cfg.IsAllowedFile(name) {
-			continue
-		}
-		if !cfg.IsSizeAllowed(f.Bytes) {
+			reason = types.SkipReasonSample
+		} else if !cfg.IsAllowedFile(name) {
+			reason = types.SkipReasonExtension
+		} else if !cfg.IsSizeAllowed(f.Bytes) {
+			reason = types.SkipReasonSize
+		}
+		if reason != "" {
+			skipped = append(skipped, types.SkippedFile{Path: strings.TrimPrefix(f.Path, "/"), Size: f.Bytes, Reason: reason})
 			continue
 		}
 
@@ -159,7 +157,39 @@ func (r *RealDebrid) getTorrentFiles(t *types.Torrent, data torrentInfo) map[str
 		files[name] = file
 		idx++
 	}
-	return files
+	return files, skipped
+}
+
+// selectTorrentFiles picks the files to download, applying the file selection rules of the debrid and the arr
+func (r *RealDebrid) selectTorrentFiles(t *types.Torrent, data torrentInfo) (map[string]types.File, []types.SkippedFile) {
+	files, skipped := r.filterTorrentFiles(t, data)
+
+	var arrRules *config.FileSelection
+	if t.Arr != nil {
+		arrRules = t.Arr.FileSelection
+	}
+	if r.fileSelection == nil && arrRules == nil {
+		return files, skipped
+	}
+
+	// Rules match on the full path, extras live in sub folders
+	paths := make(map[string]string, len(data.Files))
+	for _, f := range data.Files {
+		paths[strconv.Itoa(f.ID)] = strings.TrimPrefix(f.Path, "/")
+	}
+	candidates := make([]types.File, 0, len(files))
+	for _, f := range files {
+		f.Path = paths[f.Id]
+		candidates = append(candidates, f)
+	}
+	chosen, ruleSkipped := types.NewFileSelector(r.fileSelection, arrRules).Select(candidates)
+
+	selected := make(map[string]types.File, len(chosen))
+	for _, f := range chosen {
+		f.Path = f.Name
+		selected[f.Name] = f
+	}
+	return selected, append(skipped, ruleSkipped...)
 }
 
 func (r *RealDebrid) IsAvailable(hashes []string) map[string]bool {
@@ -189,7 +219,7 @@ func (r *RealDebrid) IsAvailable(hashes []string) map[string]bool {
 		hashStr := strings.Join(validHashes, "/")
 		url := fmt.Sprintf("%s/torrents/instantAvailability/%s", r.Host, hashStr)
 		req, _ := http.NewRequest(http.MethodGet, url, nil)
-		resp, err := r.client.MakeRequest(req)
+		resp, err := makeRequest(r.client, req)
 		if err != nil {
 			r.logger.Info().Msgf("Error checking availability: %v", err)
 			return result
@@ -200,11 +230,9 @@ func (r *RealDebrid) IsAvailable(hashes []string) map[string]bool {
 			r.logger.Info().Msgf("Error marshalling availability: %v", err)
 			return result
 		}
-		for _, h := range hashes[i:end] {
+		for _, h := range validHashes {
 			hosters, exists := data[strings.ToLower(h)]
-			if exists && len(hosters.Rd) > 0 {
-				result[h] = true
-			}
+			result[h] = exists && len(hosters.Rd) > 0
 		}
 	}
 	return result
@@ -226,7 +254,7 @@ func (r *RealDebrid) addTorrent(t *types.Torrent) (*types.Torrent, error) {
 		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/x-bittorrent")
-	resp, err := r.client.MakeRequest(req)
+	resp, err := makeRequest(r.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +274,7 @@ func (r *RealDebrid) addMagnet(t *types.Torrent) (*types.Torrent, error) {
 	}
 	var data AddMagnetSchema
 	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(payload.Encode()))
-	resp, err := r.client.MakeRequest(req)
+	resp, err := makeRequest(r.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -272,10 +300,7 @@ func (r *RealDebrid) GetTorrent(torrentId string) (*types.Torrent, error) {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, request.TorrentNotFoundError
-		}
-		return nil, fmt.Errorf("realdebrid API error: Status: %d || Body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, apiError(resp.StatusCode, bodyBytes)
 	}
 	var data torrentInfo
 	err = json.Unmarshal(bodyBytes, &data)
@@ -315,10 +340,7 @@ func (r *RealDebrid) UpdateTorrent(t *types.Torrent) error {
 		return fmt.Errorf("reading response body: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return request.TorrentNotFoundError
-		}
-		return fmt.Errorf("realdebrid API error: Status: %d || Body: %s", resp.StatusCode, string(bodyBytes))
+		return apiError(resp.StatusCode, bodyBytes)
 	}
 	var data torrentInfo
 	err = json.Unmarshal(bodyBytes, &data)
@@ -346,7 +368,7 @@ func (r *RealDebrid) CheckStatus(t *types.Torrent, isSymlink bool) (*types.Torre
 	url := fmt.Sprintf("%s/torrents/info/%s", r.Host, t.Id)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
 	for {
-		resp, err := r.client.MakeRequest(req)
+		resp, err := makeRequest(r.client, req)
 		if err != nil {
 			r.logger.Info().Msgf("ERROR Checking file: %v", err)
 			return t, err
@@ -369,7 +391,7 @@ func (r *RealDebrid) CheckStatus(t *types.Torrent, isSymlink bool) (*types.Torre
 		t.Debrid = r.Name
 		t.MountPath = r.MountPath
 		if status == "waiting_files_selection" {
-			t.Files = r.getTorrentFiles(t, data)
+			t.Files, t.SkippedFiles = r.selectTorrentFiles(t, data)
 			if len(t.Files) == 0 {
 				return t, fmt.Errorf("no video files found")
 			}
@@ -387,8 +409,11 @@ func (r *RealDebrid) CheckStatus(t *types.Torrent, isSymlink bool) (*types.Torre
 				return t, err
 			}
 			if res.StatusCode != http.StatusNoContent {
-				return t, fmt.Errorf("realdebrid API error: Status: %d", res.StatusCode)
+				body, _ := io.ReadAll(res.Body)
+				res.Body.Close()
+				return t, apiError(res.StatusCode, body)
 			}
+			res.Body.Close()
 		} else if status == "downloaded" {
 			t.Files = getSelectedFiles(t, data) // Get selected files
 			r.logger.Info().Msgf("Torrent: %s downloaded to RD", t.Name)
@@ -415,7 +440,7 @@ func (r *RealDebrid) CheckStatus(t *types.Torrent, isSymlink bool) (*types.Torre
 func (r *RealDebrid) DeleteTorrent(torrentId string) error {
 	url := fmt.Sprintf("%s/torrents/delete/%s", r.Host, torrentId)
 	req, _ := http.NewRequest(http.MethodDelete, url, nil)
-	if _, err := r.client.MakeRequest(req); err != nil {
+	if _, err := makeRequest(r.client, req); err != nil {
 		return err
 	}
 	r.logger.Info().Msgf("Torrent: %s deleted from RD", torrentId)
@@ -476,18 +501,24 @@ func (r *RealDebrid) CheckLink(link string) error {
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotFound {
 		return request.HosterUnavailableError // File has been removed
 	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return apiError(resp.StatusCode, body)
+	}
 	return nil
 }
 
-func (r *RealDebrid) _getDownloadLink(file *types.File) (*types.DownloadLink, error) {
+func (r *RealDebrid) _getDownloadLink(file *types.File, acc account.Account) (*types.DownloadLink, error) {
 	url := fmt.Sprintf("%s/unrestrict/link/", r.Host)
 	payload := gourl.Values{
 		"link": {file.Link},
 	}
 	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(payload.Encode()))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", acc.Token))
 	resp, err := r.downloadClient.Do(req)
 
 	if err != nil {
@@ -500,26 +531,7 @@ func (r *RealDebrid) _getDownloadLink(file *types.File) (*types.DownloadLink, er
 		if err != nil {
 			return nil, err
 		}
-		var data ErrorResponse
-		if err = json.Unmarshal(b, &data); err != nil {
-			return nil, fmt.Errorf("error unmarshalling %d || %s \n %s", resp.StatusCode, err, string(b))
-		}
-		switch data.ErrorCode {
-		case 19:
-			return nil, request.HosterUnavailableError // File has been removed
-		case 23:
-			return nil, request.TrafficExceededError
-		case 24:
-			return nil, request.HosterUnavailableError // Link has been nerfed
-		case 34:
-			return nil, request.TrafficExceededError // traffic exceeded
-		case 35:
-			return nil, request.HosterUnavailableError
-		case 36:
-			return nil, request.TrafficExceededError // traffic exceeded
-		default:
-			return nil, fmt.Errorf("realdebrid API error: Status: %d || Code: %d", resp.StatusCode, data.ErrorCode)
-		}
+		return nil, apiError(resp.StatusCode, b)
 	}
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -532,60 +544,32 @@ func (r *RealDebrid) _getDownloadLink(file *types.File) (*types.DownloadLink, er
 	if data.Download == "" {
 		return nil, fmt.Errorf("realdebrid API error: download link not found")
 	}
+	r.linkAccounts.Store(data.Id, acc.Token)
 	return &types.DownloadLink{
 		Filename:     data.Filename,
 		Size:         data.Filesize,
 		Link:         data.Link,
 		DownloadLink: data.Download,
+		Id:           data.Id,
 		Generated:    time.Now(),
+		AccountId:    acc.ID,
 	}, nil
 
 }
 
+// GetDownloadLink unrestricts the file with the least used account, moving on to the next one
+// when an account is out of traffic
 func (r *RealDebrid) GetDownloadLink(t *types.Torrent, file *types.File) (*types.DownloadLink, error) {
-
-	if r.currentDownloadKey == "" {
-		// If no download key is set, use the first one
-		accounts := r.getActiveAccounts()
-		if len(accounts) < 1 {
-			// No active download keys. It's likely that the key has reached bandwidth limit
-			return nil, fmt.Errorf("no active download keys")
-		}
-		r.currentDownloadKey = accounts[0].Token
-	}
-
-	r.downloadClient.SetHeader("Authorization", fmt.Sprintf("Bearer %s", r.currentDownloadKey))
-	downloadLink, err := r._getDownloadLink(file)
-	retries := 0
-	if err != nil {
-		if errors.Is(err, request.TrafficExceededError) {
-			// Retries generating
-			retries = 5
-		} else {
-			// If the error is not traffic exceeded, return the error
-			return nil, err
-		}
-	}
-	backOff := 1 * time.Second
-	for retries > 0 {
-		downloadLink, err = r._getDownloadLink(file)
-		if err == nil {
-			return downloadLink, nil
-		}
-		if !errors.Is(err, request.TrafficExceededError) {
-			return nil, err
-		}
-		// Add a delay before retrying
-		time.Sleep(backOff)
-		backOff *= 2 // Exponential backoff
-	}
-	return downloadLink, nil
+	return account.Rotate(r.accounts, "traffic exceeded", func(acc account.Account) (*types.DownloadLink, error) {
+		return r._getDownloadLink(file, acc)
+	})
 }
 
 func (r *RealDebrid) GetCheckCached() bool {
 	return r.checkCached
 }
 
+// getTorrents returns a page of torrents, newest first, in any status
 func (r *RealDebrid) getTorrents(offset int, limit int) (int, []*types.Torrent, error) {
 	url := fmt.Sprintf("%s/torrents?limit=%d", r.Host, limit)
 	torrents := make([]*types.Torrent, 0)
@@ -604,8 +588,9 @@ func (r *RealDebrid) getTorrents(offset int, limit int) (int, []*types.Torrent,
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return 0, torrents, fmt.Errorf("realdebrid API error: %d", resp.StatusCode)
+		return 0, torrents, apiError(resp.StatusCode, body)
 	}
 
 	defer resp.Body.Close()
@@ -613,16 +598,11 @@ func (r *RealDebrid) getTorrents(offset int, limit int) (int, []*types.Torrent,
 	if err != nil {
 		return 0, torrents, err
 	}
-	totalItems, _ := strconv.Atoi(resp.Header.Get("X-Total-Count"))
 	var data []TorrentsResponse
 	if err = json.Unmarshal(body, &data); err != nil {
 		return 0, torrents, err
 	}
-	filenames := map[string]struct{}{}
 	for _, t := range data {
-		if t.Status != "downloaded" {
-			continue
-		}
 		torrents = append(torrents, &types.Torrent{
 			Id:               t.Id,
 			Name:             t.Filename,
@@ -638,9 +618,18 @@ func (r *RealDebrid) getTorrents(offset int, limit int) (int, []*types.Torrent,
 			MountPath:        r.MountPath,
 			Added:            t.Added.Format(time.RFC3339),
 		})
-		filenames[t.Filename] = struct{}{}
 	}
-	return totalItems, torrents, nil
+	return len(data), torrents, nil
+}
+
+// trackPending remembers whether a torrent is still downloading. Returns true if it's downloaded.
+func (r *RealDebrid) trackPending(t *types.Torrent) bool {
+	if utils.Contains(r.GetDownloadingStatus(), t.Status) {
+		r.pending.Store(t.Id, struct{}{})
+	} else {
+		r.pending.Delete(t.Id)
+	}
+	return t.Status == "downloaded"
 }
 
 func (r *RealDebrid) GetTorrents() ([]*types.Torrent, error) {
@@ -652,17 +641,20 @@ func (r *RealDebrid) GetTorrents() ([]*types.Torrent, error) {
 	offset := 0
 	for {
 		// Fetch next batch of torrents
-		_, torrents, err := r.getTorrents(offset, limit)
+		pageSize, torrents, err := r.getTorrents(offset, limit)
 		if err != nil {
 			fetchError = err
 			break
 		}
-		totalTorrents := len(torrents)
-		if totalTorrents == 0 {
+		if pageSize == 0 {
 			break
 		}
-		allTorrents = append(allTorrents, torrents...)
-		offset += totalTorrents
+		for _, t := range torrents {
+			if r.trackPending(t) {
+				allTorrents = append(allTorrents, t)
+			}
+		}
+		offset += pageSize
 	}
 
 	if fetchError != nil {
@@ -672,47 +664,98 @@ func (r *RealDebrid) GetTorrents() ([]*types.Torrent, error) {
 	return allTorrents, nil
 }
 
-func (r *RealDebrid) GetDownloads() (map[string]types.DownloadLink, error) {
-	links := make(map[string]types.DownloadLink)
+// GetNewTorrents pages through the torrents, newest first, until it reaches a known one. Torrents finish in
+// any order, so it goes on until it has also seen the ones that were still downloading on the last sync.
+func (r *RealDebrid) GetNewTorrents(known func(id string) bool) ([]*types.Torrent, error) {
+	limit := 100
+	newTorrents := make([]*types.Torrent, 0)
+	waiting := make(map[string]struct{})
+	r.pending.Range(func(id, _ any) bool {
+		waiting[id.(string)] = struct{}{}
+		return true
+	})
 	offset := 0
-	limit := 1000
-
-	accounts := r.getActiveAccounts()
-
-	if len(accounts) < 1 {
-		// No active download keys. It's likely that the key has reached bandwidth limit
-		return nil, fmt.Errorf("no active download keys")
-	}
-	r.downloadClient.SetHeader("Authorization", fmt.Sprintf("Bearer %s", accounts[0].Token))
+	reachedKnown := false
 	for {
-		dl, err := r._getDownloads(offset, limit)
+		pageSize, torrents, err := r.getTorrents(offset, limit)
 		if err != nil {
+			return nil, err
+		}
+		for _, t := range torrents {
+			delete(waiting, t.Id)
+			if !r.trackPending(t) {
+				continue
+			}
+			if known(t.Id) {
+				reachedKnown = true
+				continue
+			}
+			newTorrents = append(newTorrents, t)
+		}
+		if pageSize < limit {
+			// The end of the list, the torrents still waited for were deleted
+			for id := range waiting {
+				r.pending.Delete(id)
+			}
 			break
 		}
-		if len(dl) == 0 {
+		if reachedKnown && len(waiting) == 0 {
 			break
 		}
+		offset += pageSize
+	}
+	return newTorrents, nil
+}
 
-		for _, d := range dl {
-			if _, exists := links[d.Link]; exists {
-				// This is ordered by date, so we can skip the rest
-				continue
+// GetDownloads returns the download links of every account, the newest one of a file wins
+func (r *RealDebrid) GetDownloads() (map[string]types.DownloadLink, error) {
+	links := make(map[string]types.DownloadLink)
+	limit := 1000
+
+	accounts := r.accounts.List()
+	failed := 0
+	var lastErr error
+	for _, acc := range accounts {
+		offset := 0
+		for {
+			dl, err := r._getDownloads(offset, limit, acc)
+			if err != nil {
+				if offset == 0 {
+					failed++
+					lastErr = err
+				}
+				r.logger.Debug().Err(err).Msgf("Failed to get download links of %s", acc.Name)
+				break
+			}
+			if len(dl) == 0 {
+				break
+			}
+
+			for _, d := range dl {
+				r.linkAccounts.Store(d.Id, acc.Token)
+				if existing, exists := links[d.Link]; exists && !d.Generated.After(existing.Generated) {
+					continue
+				}
+				links[d.Link] = d
 			}
-			links[d.Link] = d
-		}
 
-		offset += len(dl)
+			offset += len(dl)
+		}
+	}
+	if len(accounts) > 0 && failed == len(accounts) {
+		return nil, lastErr
 	}
 	return links, nil
 }
 
-func (r *RealDebrid) _getDownloads(offset int, limit int) ([]types.DownloadLink, error) {
+func (r *RealDebrid) _getDownloads(offset int, limit int, acc account.Account) ([]types.DownloadLink, error) {
 	url := fmt.Sprintf("%s/downloads?limit=%d", r.Host, limit)
 	if offset > 0 {
 		url = fmt.Sprintf("%s&offset=%d", url, offset)
 	}
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := r.downloadClient.MakeRequest(req)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", acc.Token))
+	resp, err := makeRequest(r.downloadClient, req)
 	if err != nil {
 		return nil, err
 	}
@@ -729,6 +772,7 @@ func (r *RealDebrid) _getDownloads(offset int, limit int) ([]types.DownloadLink,
 			DownloadLink: d.Download,
 			Generated:    d.Generated,
 			Id:           d.Id,
+			AccountId:    acc.ID,
 		})
 
 	}
@@ -748,52 +792,28 @@ func (r *RealDebrid) GetMountPath() string {
 }
 
 func (r *RealDebrid) DisableAccount(accountId string) {
-	r.accountsMutex.Lock()
-	defer r.accountsMutex.Unlock()
-	if len(r.accounts) == 1 {
-		r.logger.Info().Msgf("Cannot disable last account: %s", accountId)
-		return
-	}
-	r.currentDownloadKey = ""
-	if value, ok := r.accounts[accountId]; ok {
-		value.Disabled = true
-		r.accounts[accountId] = value
-		r.logger.Info().Msgf("Disabled account Index: %s", value.ID)
-	}
+	r.accounts.Disable(accountId, "bandwidth exceeded")
 }
 
 func (r *RealDebrid) ResetActiveDownloadKeys() {
-	r.accountsMutex.Lock()
-	defer r.accountsMutex.Unlock()
-	for key, value := range r.accounts {
-		value.Disabled = false
-		r.accounts[key] = value
-	}
+	r.accounts.Reset()
 }
 
-func (r *RealDebrid) getActiveAccounts() []types.Account {
-	r.accountsMutex.RLock()
-	defer r.accountsMutex.RUnlock()
-	accounts := make([]types.Account, 0)
-
-	for _, value := range r.accounts {
-		if value.Disabled {
-			continue
-		}
-		accounts = append(accounts, value)
-	}
-
-	// Sort accounts by ID
-	sort.Slice(accounts, func(i, j int) bool {
-		return accounts[i].ID < accounts[j].ID
-	})
-	return accounts
+func (r *RealDebrid) Accounts() *account.Manager {
+	return r.accounts
 }
 
+// DeleteDownloadLink deletes a download link with the account that created it. Links of no known account are
+// left alone, another account can't delete them.
 func (r *RealDebrid) DeleteDownloadLink(linkId string) error {
+	token, ok := r.linkAccounts.LoadAndDelete(linkId)
+	if !ok {
+		return nil
+	}
 	url := fmt.Sprintf("%s/downloads/delete/%s", r.Host, linkId)
 	req, _ := http.NewRequest(http.MethodDelete, url, nil)
-	if _, err := r.downloadClient.MakeRequest(req); err != nil {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	if _, err := makeRequest(r.downloadClient, req); err != nil {
 		return err
 	}
 	return nil
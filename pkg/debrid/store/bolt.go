@@ -0,0 +1,306 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	torrentsBucket = []byte("torrents")
+	trashBucket    = []byte("trash")
+	metaBucket     = []byte("meta")
+)
+
+// compactTxSize is the size of the transactions used to copy the database when compacting
+const compactTxSize = 64 << 20
+
+// Bolt is a Store kept in a single bbolt file
+type Bolt struct {
+	// mu is held for writing while the database file is swapped by Compact
+	mu   sync.RWMutex
+	db   *bolt.DB
+	path string
+}
+
+// OpenBolt opens, or creates, the database at path
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Bolt{db: db, path: path}, nil
+}
+
+func openDB(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{torrentsBucket, trashBucket, metaBucket, []byte(Links), []byte(InvalidLinks)} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+	return db, nil
+}
+
+func (s *Bolt) ForEach(fn func(id string, data []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(torrentsBucket).ForEach(func(k, v []byte) error {
+			// Values are only valid for the life of the transaction
+			return fn(string(k), append([]byte(nil), v...))
+		})
+	})
+}
+
+func (s *Bolt) Put(id string, data []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		return tx.Bucket(torrentsBucket).Put([]byte(id), data)
+	})
+}
+
+func (s *Bolt) PutAll(items map[string][]byte) error {
+	if len(items) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(torrentsBucket)
+		for id, data := range items {
+			if err := b.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// trashEntry is how a torrent is kept in the trash bucket
+type trashEntry struct {
+	TrashedAt time.Time       `json:"trashed_at"`
+	Torrent   json.RawMessage `json:"torrent"`
+}
+
+func (s *Bolt) Trash(id string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		torrents := tx.Bucket(torrentsBucket)
+		data := torrents.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		entry, err := json.Marshal(trashEntry{
+			TrashedAt: time.Now(),
+			Torrent:   data,
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(trashBucket).Put([]byte(id), entry); err != nil {
+			return err
+		}
+		return torrents.Delete([]byte(id))
+	})
+}
+
+func decodeTrashed(id, value []byte) (Trashed, error) {
+	var entry trashEntry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return Trashed{}, fmt.Errorf("failed to decode trashed torrent %s: %w", id, err)
+	}
+	return Trashed{
+		ID:        string(id),
+		Data:      append([]byte(nil), entry.Torrent...),
+		TrashedAt: entry.TrashedAt,
+	}, nil
+}
+
+func (s *Bolt) ForEachTrashed(fn func(t Trashed) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(trashBucket).ForEach(func(k, v []byte) error {
+			t, err := decodeTrashed(k, v)
+			if err != nil {
+				// Skip the entry rather than hiding the rest of the trash
+				return nil
+			}
+			return fn(t)
+		})
+	})
+}
+
+func (s *Bolt) GetTrashed(id string) (Trashed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var t Trashed
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(trashBucket).Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		var err error
+		t, err = decodeTrashed([]byte(id), value)
+		return err
+	})
+	return t, err
+}
+
+func (s *Bolt) DeleteTrashed(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(trashBucket)
+		for _, id := range ids {
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) ForEachLink(bucket LinkBucket, fn func(key string, data []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).ForEach(func(k, v []byte) error {
+			return fn(string(k), append([]byte(nil), v...))
+		})
+	})
+}
+
+func (s *Bolt) PutLinks(bucket LinkBucket, items map[string][]byte) error {
+	if len(items) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		for key, data := range items {
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) DeleteLinks(bucket LinkBucket, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) ClearLinks(bucket LinkBucket) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(bucket)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte(bucket))
+		return err
+	})
+}
+
+func (s *Bolt) GetMeta(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get([]byte(key)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data, err
+}
+
+func (s *Bolt) PutMeta(key string, data []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put([]byte(key), data)
+	})
+}
+
+// Compact copies the database to a new file and swaps it in. bbolt never shrinks its file on its own.
+func (s *Bolt) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := s.path + ".compact"
+	_ = os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmpPath, err)
+	}
+	if err := bolt.Compact(dst, s.db, compactTxSize); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := s.db.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		_ = os.Remove(tmpPath)
+		// Keep serving from the old file
+		if db, err := openDB(s.path); err == nil {
+			s.db = db
+		}
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	// On failure s.db stays closed and every call returns an error instead of panicking
+	db, err := openDB(s.path)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+func (s *Bolt) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
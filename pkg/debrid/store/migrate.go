@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// migrateBatchSize is the number of torrents written per transaction when migrating
+const migrateBatchSize = 1000
+
+// MigrateJSON imports the <id>.json files, and their trash, written by older versions into s.
+// The directory is renamed to <dir>.migrated once imported so it is only migrated once.
+// Returns the number of torrents imported.
+func MigrateJSON(dir string, s Store) (int, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	// Import the trash first so a torrent saved again after being trashed stays live
+	trashed, err := readJSONFiles(filepath.Join(dir, "trash"))
+	if err != nil {
+		return 0, err
+	}
+	for id, data := range trashed {
+		if err := s.Put(id, data); err != nil {
+			return 0, fmt.Errorf("failed to import trashed torrent %s: %w", id, err)
+		}
+		if err := s.Trash(id); err != nil {
+			return 0, fmt.Errorf("failed to import trashed torrent %s: %w", id, err)
+		}
+	}
+
+	torrents, err := readJSONFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	batch := make(map[string][]byte, min(migrateBatchSize, len(torrents)))
+	for id, data := range torrents {
+		batch[id] = data
+		if len(batch) < migrateBatchSize {
+			continue
+		}
+		if err := s.PutAll(batch); err != nil {
+			return 0, fmt.Errorf("failed to import torrents: %w", err)
+		}
+		batch = make(map[string][]byte, migrateBatchSize)
+	}
+	if err := s.PutAll(batch); err != nil {
+		return 0, fmt.Errorf("failed to import torrents: %w", err)
+	}
+
+	if err := os.Rename(dir, dir+".migrated"); err != nil {
+		return len(torrents), fmt.Errorf("failed to rename %s: %w", dir, err)
+	}
+	return len(torrents), nil
+}
+
+func readJSONFiles(dir string) (map[string][]byte, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	items := make(map[string][]byte, len(files))
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name(), err)
+		}
+		items[strings.TrimSuffix(file.Name(), ".json")] = data
+	}
+	return items, nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("torrent not found")
+
+// Trashed is a torrent moved to the trash
+type Trashed struct {
+	ID        string
+	Data      []byte
+	TrashedAt time.Time
+}
+
+// LinkBucket is where a kind of download link is saved
+type LinkBucket string
+
+const (
+	Links        LinkBucket = "links"         // unrestricted links, keyed by file link
+	InvalidLinks LinkBucket = "invalid_links" // links that failed, keyed by download link
+)
+
+// Store persists the torrents of a debrid's WebDAV cache. Torrents are saved as opaque blobs keyed by ID.
+type Store interface {
+	// ForEach calls fn with every saved torrent. data may be retained by fn.
+	ForEach(fn func(id string, data []byte) error) error
+	// Put saves a torrent. Concurrent calls are committed together.
+	Put(id string, data []byte) error
+	// PutAll saves torrents in a single transaction
+	PutAll(items map[string][]byte) error
+	// Trash moves a torrent to the trash
+	Trash(id string) error
+	// ForEachTrashed calls fn with every torrent in the trash
+	ForEachTrashed(fn func(t Trashed) error) error
+	// GetTrashed returns a torrent from the trash, or ErrNotFound
+	GetTrashed(id string) (Trashed, error)
+	// DeleteTrashed removes torrents from the trash for good
+	DeleteTrashed(ids ...string) error
+	// ForEachLink calls fn with every download link saved in bucket
+	ForEachLink(bucket LinkBucket, fn func(key string, data []byte) error) error
+	// PutLinks saves download links. Concurrent calls are committed together.
+	PutLinks(bucket LinkBucket, items map[string][]byte) error
+	// DeleteLinks removes download links. Concurrent calls are committed together.
+	DeleteLinks(bucket LinkBucket, keys ...string) error
+	// ClearLinks removes every download link saved in bucket
+	ClearLinks(bucket LinkBucket) error
+	// GetMeta returns a value saved with PutMeta, or nil
+	GetMeta(key string) ([]byte, error)
+	// PutMeta saves state that isn't a torrent, like scan progress
+	PutMeta(key string, data []byte) error
+	// Compact rewrites the store to reclaim the space left by deleted torrents
+	Compact() error
+	Close() error
+}
@@ -0,0 +1,55 @@
+package torbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sirrobot01/decypharr/internal/request"
+)
+
+// errorKinds maps Torbox error codes
+var errorKinds = map[string]*request.HTTPError{
+	"NO_AUTH":                    request.AuthInvalidError,
+	"BAD_TOKEN":                  request.AuthInvalidError,
+	"AUTH_ERROR":                 request.AuthInvalidError,
+	"ITEM_NOT_FOUND":             request.TorrentNotFoundError,
+	"MONTHLY_LIMIT":              request.TrafficExceededError,
+	"COOLDOWN_LIMIT":             request.TrafficExceededError,
+	"ACTIVE_LIMIT":               request.TooManyActiveDownloadsError,
+	"DOWNLOAD_SERVER_ERROR":      request.ServiceUnavailableError,
+	"NO_SERVERS_AVAILABLE_ERROR": request.ServiceUnavailableError,
+	"LINK_OFFLINE":               request.HosterUnavailableError,
+}
+
+// apiError maps a failed Torbox response to a request error kind
+func apiError(statusCode int, code any, detail string) error {
+	kind, ok := errorKinds[fmt.Sprint(code)]
+	if !ok {
+		kind = request.StatusError(statusCode)
+	}
+	return request.ProviderError(kind, "torbox API error: Status: %d || Code: %v || %s", statusCode, code, detail)
+}
+
+// makeRequest is request.Client.MakeRequest, with Torbox error codes mapped
+func makeRequest(client *request.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	var data APIResponse[json.RawMessage]
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = json.Unmarshal(body, &data)
+		return nil, apiError(resp.StatusCode, data.Error, data.Detail)
+	}
+	if err := json.Unmarshal(body, &data); err == nil && !data.Success && data.Error != nil {
+		return nil, apiError(resp.StatusCode, data.Error, data.Detail)
+	}
+	return body, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/sirrobot01/decypharr/internal/logger"
 	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/account"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"github.com/sirrobot01/decypharr/pkg/version"
 	"mime/multipart"
@@ -24,10 +25,14 @@ import (
 )
 
 type Torbox struct {
-	Name             string
-	Host             string `json:"host"`
-	APIKey           string
-	accounts         map[string]types.Account
+	Name     string
+	Host     string `json:"host"`
+	APIKey   string
+	accounts *account.Manager
+
+	// mainAccountId is the download key matching APIKey, the torrents belong to it
+	mainAccountId string
+
 	DownloadUncached bool
 	client           *request.Client
 
@@ -37,7 +42,7 @@ type Torbox struct {
 	addSamples  bool
 }
 
-func New(dc config.Debrid) *Torbox {
+func New(dc config.Debrid, opts ...request.ClientOption) *Torbox {
 	rl := request.ParseRateLimit(dc.RateLimit)
 
 	headers := map[string]string{
@@ -45,28 +50,26 @@ func New(dc config.Debrid) *Torbox {
 		"User-Agent":    fmt.Sprintf("Decypharr/%s (%s; %s)", version.GetInfo(), runtime.GOOS, runtime.GOARCH),
 	}
 	_log := logger.New(dc.Name)
-	client := request.New(
+	client := request.New(append([]request.ClientOption{
 		request.WithHeaders(headers),
 		request.WithRateLimiter(rl),
 		request.WithLogger(_log),
 		request.WithProxy(dc.Proxy),
-	)
+	}, opts...)...)
 
-	accounts := make(map[string]types.Account)
+	mainAccountId := ""
 	for idx, key := range dc.DownloadAPIKeys {
-		id := strconv.Itoa(idx)
-		accounts[id] = types.Account{
-			Name:  key,
-			ID:    id,
-			Token: key,
+		if key == dc.APIKey {
+			mainAccountId = strconv.Itoa(idx)
+			break
 		}
 	}
-
 	return &Torbox{
 		Name:             "torbox",
 		Host:             "https://api.torbox.app/v1",
 		APIKey:           dc.APIKey,
-		accounts:         accounts,
+		accounts:         account.NewManager(dc.Name, dc.DownloadAPIKeys),
+		mainAccountId:    mainAccountId,
 		DownloadUncached: dc.DownloadUncached,
 		client:           client,
 		MountPath:        dc.Folder,
@@ -111,7 +114,7 @@ func (tb *Torbox) IsAvailable(hashes []string) map[string]bool {
 		hashStr := strings.Join(validHashes, ",")
 		url := fmt.Sprintf("%s/api/torrents/checkcached?hash=%s", tb.Host, hashStr)
 		req, _ := http.NewRequest(http.MethodGet, url, nil)
-		resp, err := tb.client.MakeRequest(req)
+		resp, err := makeRequest(tb.client, req)
 		if err != nil {
 			tb.logger.Info().Msgf("Error checking availability: %v", err)
 			return result
@@ -122,15 +125,18 @@ func (tb *Torbox) IsAvailable(hashes []string) map[string]bool {
 			tb.logger.Info().Msgf("Error marshalling availability: %v", err)
 			return result
 		}
-		if res.Data == nil {
-			return result
-		}
-
-		for h, c := range *res.Data {
-			if c.Size > 0 {
-				result[strings.ToUpper(h)] = true
+		// Only the cached hashes are listed
+		cached := make(map[string]bool)
+		if res.Data != nil {
+			for h, c := range *res.Data {
+				if c.Size > 0 {
+					cached[strings.ToLower(h)] = true
+				}
 			}
 		}
+		for _, h := range validHashes {
+			result[h] = cached[strings.ToLower(h)]
+		}
 	}
 	return result
 }
@@ -146,7 +152,7 @@ func (tb *Torbox) SubmitMagnet(torrent *types.Torrent) (*types.Torrent, error) {
 	}
 	req, _ := http.NewRequest(http.MethodPost, url, payload)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	resp, err := tb.client.MakeRequest(req)
+	resp, err := makeRequest(tb.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +192,7 @@ func getTorboxStatus(status string, finished bool) string {
 func (tb *Torbox) GetTorrent(torrentId string) (*types.Torrent, error) {
 	url := fmt.Sprintf("%s/api/torrents/mylist/?id=%s", tb.Host, torrentId)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := tb.client.MakeRequest(req)
+	resp, err := makeRequest(tb.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -254,7 +260,7 @@ func (tb *Torbox) GetTorrent(torrentId string) (*types.Torrent, error) {
 func (tb *Torbox) UpdateTorrent(t *types.Torrent) error {
 	url := fmt.Sprintf("%s/api/torrents/mylist/?id=%s", tb.Host, t.Id)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := tb.client.MakeRequest(req)
+	resp, err := makeRequest(tb.client, req)
 	if err != nil {
 		return err
 	}
@@ -348,7 +354,7 @@ func (tb *Torbox) DeleteTorrent(torrentId string) error {
 	payload := map[string]string{"torrent_id": torrentId, "action": "Delete"}
 	jsonPayload, _ := json.Marshal(payload)
 	req, _ := http.NewRequest(http.MethodDelete, url, bytes.NewBuffer(jsonPayload))
-	if _, err := tb.client.MakeRequest(req); err != nil {
+	if _, err := makeRequest(tb.client, req); err != nil {
 		return err
 	}
 	tb.logger.Info().Msgf("Torrent %s deleted from Torbox", torrentId)
@@ -396,6 +402,8 @@ func (tb *Torbox) GenerateDownloadLinks(t *types.Torrent) error {
 	return nil
 }
 
+// GetDownloadLink requests the link with APIKey. The torrents belong to it, the other download keys
+// can't request links for them.
 func (tb *Torbox) GetDownloadLink(t *types.Torrent, file *types.File) (*types.DownloadLink, error) {
 	url := fmt.Sprintf("%s/api/torrents/requestdl/", tb.Host)
 	query := gourl.Values{}
@@ -404,7 +412,7 @@ func (tb *Torbox) GetDownloadLink(t *types.Torrent, file *types.File) (*types.Do
 	query.Add("file_id", file.Id)
 	url += "?" + query.Encode()
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := tb.client.MakeRequest(req)
+	resp, err := makeRequest(tb.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -423,7 +431,7 @@ func (tb *Torbox) GetDownloadLink(t *types.Torrent, file *types.File) (*types.Do
 		Link:         file.Link,
 		DownloadLink: link,
 		Id:           file.Id,
-		AccountId:    "0",
+		AccountId:    tb.mainAccountId,
 		Generated:    time.Now(),
 	}, nil
 }
@@ -457,12 +465,18 @@ func (tb *Torbox) GetMountPath() string {
 }
 
 func (tb *Torbox) DisableAccount(accountId string) {
+	tb.accounts.Disable(accountId, "bandwidth exceeded")
 }
 
 func (tb *Torbox) ResetActiveDownloadKeys() {
+	tb.accounts.Reset()
+}
 
+func (tb *Torbox) Accounts() *account.Manager {
+	return tb.accounts
 }
 
+// DeleteDownloadLink is a no-op, Torbox links are not stored on the account
 func (tb *Torbox) DeleteDownloadLink(linkId string) error {
 	return nil
 }
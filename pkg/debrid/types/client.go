@@ -10,6 +10,8 @@ type Client interface {
 	GenerateDownloadLinks(tr *Torrent) error
 	GetDownloadLink(tr *Torrent, file *File) (*DownloadLink, error)
 	DeleteTorrent(torrentId string) error
+	// IsAvailable returns, for the hashes the debrid answered for, whether they're cached.
+	// Hashes it couldn't check, e.g. when the request fails, are left out.
 	IsAvailable(infohashes []string) map[string]bool
 	GetCheckCached() bool
 	GetDownloadUncached() bool
@@ -26,3 +28,10 @@ type Client interface {
 	ResetActiveDownloadKeys()
 	DeleteDownloadLink(linkId string) error
 }
+
+// IncrementalLister is implemented by clients that list torrents newest first.
+// GetNewTorrents stops paging once it reaches a torrent for which known returns true, and has seen the
+// torrents that were still downloading on the last call.
+type IncrementalLister interface {
+	GetNewTorrents(known func(id string) bool) ([]*Torrent, error)
+}
@@ -0,0 +1,199 @@
+package types
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirrobot01/decypharr/internal/config"
+)
+
+// Reasons a file was left out of a torrent
+const (
+	SkipReasonExtension = "extension"
+	SkipReasonSize      = "size"
+	SkipReasonSample    = "sample"
+	SkipReasonInclude   = "not included"
+	SkipReasonExclude   = "excluded"
+	SkipReasonLanguage  = "language"
+	SkipReasonEpisode   = "not an episode"
+	SkipReasonExtras    = "extras"
+	SkipReasonLargest   = "not among the largest"
+)
+
+// SkippedFile is a torrent file that was not selected for download
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+var (
+	episodeRegex = regexp.MustCompile(`(?i)(\bs\d{1,3}[ ._-]?e\d{1,4}\b|\b\d{1,2}x\d{2,3}\b|\bepisode[ ._-]?\d{1,4}\b)`)
+	tokenRegex   = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+	extrasFolders = []string{
+		"extras", "extra", "featurettes", "featurette", "behind the scenes", "deleted scenes",
+		"interviews", "scenes", "shorts", "trailers", "bonus", "other",
+	}
+
+	// languageAliases maps the tags found in file names to a language.
+	// Two-letter codes are left out, they clash with words too often (It, De, To...).
+	languageAliases = map[string]string{
+		"eng": "english", "english": "english",
+		"fre": "french", "fra": "french", "french": "french", "truefrench": "french", "vff": "french", "vf": "french",
+		"ger": "german", "deu": "german", "german": "german",
+		"spa": "spanish", "esp": "spanish", "spanish": "spanish", "castellano": "spanish", "latino": "spanish",
+		"ita": "italian", "italian": "italian",
+		"jpn": "japanese", "jap": "japanese", "japanese": "japanese",
+		"por": "portuguese", "portuguese": "portuguese",
+		"rus": "russian", "russian": "russian",
+		"dut": "dutch", "nld": "dutch", "dutch": "dutch",
+		"pol": "polish", "polish": "polish",
+		"kor": "korean", "korean": "korean",
+		"chi": "chinese", "zho": "chinese", "chinese": "chinese",
+		"hin": "hindi", "hindi": "hindi",
+		"swe": "swedish", "swedish": "swedish",
+		"nor": "norwegian", "norwegian": "norwegian",
+		"dan": "danish", "danish": "danish",
+		"fin": "finnish", "finnish": "finnish",
+		"tur": "turkish", "turkish": "turkish",
+		"ara": "arabic", "arabic": "arabic",
+	}
+	// Configured languages may also use two-letter codes
+	languageCodes = map[string]string{
+		"en": "english", "fr": "french", "de": "german", "es": "spanish", "it": "italian", "ja": "japanese",
+		"pt": "portuguese", "ru": "russian", "nl": "dutch", "pl": "polish", "ko": "korean", "zh": "chinese",
+		"hi": "hindi", "sv": "swedish", "no": "norwegian", "da": "danish", "fi": "finnish", "tr": "turkish", "ar": "arabic",
+	}
+)
+
+// FileSelector applies config.FileSelection rules to the files of a torrent
+type FileSelector struct {
+	include      []*regexp.Regexp
+	exclude      []*regexp.Regexp
+	languages    []string
+	largest      int
+	episodesOnly bool
+	skipExtras   bool
+}
+
+// NewFileSelector combines the rules, every rule set must accept a file for it to be selected.
+// nil rule sets are ignored.
+func NewFileSelector(rules ...*config.FileSelection) *FileSelector {
+	fs := &FileSelector{}
+	for _, r := range rules {
+		if r == nil {
+			continue
+		}
+		for _, expr := range r.Include {
+			if re, err := regexp.Compile(expr); err == nil {
+				fs.include = append(fs.include, re)
+			}
+		}
+		for _, expr := range r.Exclude {
+			if re, err := regexp.Compile(expr); err == nil {
+				fs.exclude = append(fs.exclude, re)
+			}
+		}
+		for _, l := range r.Languages {
+			if lang := normalizeLanguage(l); lang != "" {
+				fs.languages = append(fs.languages, lang)
+			}
+		}
+		if r.Largest > 0 && (fs.largest == 0 || r.Largest < fs.largest) {
+			fs.largest = r.Largest
+		}
+		fs.episodesOnly = fs.episodesOnly || r.EpisodesOnly
+		fs.skipExtras = fs.skipExtras || r.SkipExtras
+	}
+	return fs
+}
+
+func normalizeLanguage(l string) string {
+	l = strings.ToLower(strings.TrimSpace(l))
+	if lang, ok := languageCodes[l]; ok {
+		return lang
+	}
+	if lang, ok := languageAliases[l]; ok {
+		return lang
+	}
+	return l
+}
+
+// Select splits files into the selected ones and the skipped ones, with the reason they were skipped
+func (fs *FileSelector) Select(files []File) ([]File, []SkippedFile) {
+	selected := make([]File, 0, len(files))
+	var skipped []SkippedFile
+	for _, f := range files {
+		if reason := fs.reject(f); reason != "" {
+			skipped = append(skipped, SkippedFile{Path: f.Path, Size: f.Size, Reason: reason})
+			continue
+		}
+		selected = append(selected, f)
+	}
+
+	if fs.largest > 0 && len(selected) > fs.largest {
+		sort.SliceStable(selected, func(i, j int) bool {
+			return selected[i].Size > selected[j].Size
+		})
+		for _, f := range selected[fs.largest:] {
+			skipped = append(skipped, SkippedFile{Path: f.Path, Size: f.Size, Reason: SkipReasonLargest})
+		}
+		selected = selected[:fs.largest]
+	}
+	return selected, skipped
+}
+
+func (fs *FileSelector) reject(f File) string {
+	path := f.Path
+	if path == "" {
+		path = f.Name
+	}
+	if len(fs.include) > 0 && !slices.ContainsFunc(fs.include, func(re *regexp.Regexp) bool { return re.MatchString(path) }) {
+		return SkipReasonInclude
+	}
+	if slices.ContainsFunc(fs.exclude, func(re *regexp.Regexp) bool { return re.MatchString(path) }) {
+		return SkipReasonExclude
+	}
+	if fs.skipExtras && inExtrasFolder(path) {
+		return SkipReasonExtras
+	}
+	if fs.episodesOnly && !episodeRegex.MatchString(filepath.Base(path)) {
+		return SkipReasonEpisode
+	}
+	if len(fs.languages) > 0 {
+		if tags := fileLanguages(filepath.Base(path)); len(tags) > 0 && !slices.ContainsFunc(tags, func(l string) bool {
+			return slices.Contains(fs.languages, l)
+		}) {
+			return SkipReasonLanguage
+		}
+	}
+	return ""
+}
+
+func inExtrasFolder(path string) bool {
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	for _, dir := range dirs {
+		if slices.Contains(extrasFolders, strings.ToLower(dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileLanguages returns the languages tagged in a file name. Multi-language releases match any language.
+func fileLanguages(name string) []string {
+	var langs []string
+	for _, token := range tokenRegex.Split(strings.ToLower(name), -1) {
+		if token == "multi" {
+			return nil
+		}
+		if lang, ok := languageAliases[token]; ok && !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
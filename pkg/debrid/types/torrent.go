@@ -30,7 +30,11 @@ type Torrent struct {
 	Links            []string        `json:"links"`
 	MountPath        string          `json:"mount_path"`
 
-	Debrid string `json:"debrid"`
+	Debrid          string `json:"debrid"`
+	Cached          bool   `json:"cached"`           // Whether the debrid had the torrent cached when it was selected
+	SelectionReason string `json:"selection_reason"` // Why this debrid was selected
+
+	SkippedFiles []SkippedFile `json:"skipped_files,omitempty"` // Files left out by the file selection
 
 	Arr              *arr.Arr   `json:"arr"`
 	Mu               sync.Mutex `json:"-"`
@@ -104,10 +108,3 @@ func (t *Torrent) GetFile(id string) *File {
 	}
 	return nil
 }
-
-type Account struct {
-	ID       string `json:"id"`
-	Disabled bool   `json:"disabled"`
-	Name     string `json:"name"`
-	Token    string `json:"token"`
-}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"github.com/cavaliergopher/grab/v3"
 	"github.com/sirrobot01/decypharr/internal/utils"
+	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
 	debridTypes "github.com/sirrobot01/decypharr/pkg/debrid/types"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -282,6 +285,73 @@ func (q *QBit) createSymlinksWebdav(debridTorrent *debridTypes.Torrent, rclonePa
 	return symlinkPath, nil
 }
 
+// createStrmFiles writes a .strm file for each media file of the torrent, they don't need the mount
+func (q *QBit) createStrmFiles(cache *debrid.Cache, debridTorrent *debridTypes.Torrent, torrentFolder string) (string, error) {
+	strmPath := filepath.Join(q.DownloadFolder, debridTorrent.Arr.Name, torrentFolder) // /mnt/symlinks/{category}/MyTVShow/
+	if err := os.MkdirAll(strmPath, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create directory: %s: %v", strmPath, err)
+	}
+	torrentName := cache.GetTorrentFolder(debridTorrent)
+	files := cache.GetStrmFiles(debridTorrent)
+	if len(files) == 0 {
+		return "", fmt.Errorf("no video files found")
+	}
+	for strmName, filename := range files {
+		fileStrmPath := filepath.Join(strmPath, strmName)
+		if err := os.WriteFile(fileStrmPath, []byte(cache.StrmURL(torrentName, filename)), 0644); err != nil {
+			return strmPath, fmt.Errorf("failed to write %s: %v", fileStrmPath, err)
+		}
+		q.logger.Info().Msgf("File is ready: %s", strmName)
+	}
+	return strmPath, nil
+}
+
+// relinkMovedTorrent points the symlinks of a torrent a repair moved to another debrid at its new mount path
+func (q *QBit) relinkMovedTorrent(m debrid.TorrentMove) {
+	oldPath := filepath.Clean(m.OldPath) + string(filepath.Separator)
+	relinked := 0
+	if q.DownloadFolder != "" {
+		err := filepath.WalkDir(q.DownloadFolder, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.Type()&fs.ModeSymlink == 0 {
+				return nil
+			}
+			target, err := os.Readlink(path)
+			if err != nil {
+				return nil
+			}
+			rel, ok := strings.CutPrefix(target, oldPath)
+			if !ok {
+				return nil
+			}
+			// Swap the link in place so it never dangles
+			tmp := path + ".relink"
+			if err := os.Symlink(filepath.Join(m.NewPath, rel), tmp); err != nil {
+				q.logger.Debug().Msgf("Failed to create symlink: %s: %v", tmp, err)
+				return nil
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				_ = os.Remove(tmp)
+				q.logger.Debug().Msgf("Failed to replace symlink: %s: %v", path, err)
+				return nil
+			}
+			relinked++
+			return nil
+		})
+		if err != nil {
+			q.logger.Error().Msgf("Failed to relink %s: %v", m.InfoHash, err)
+		}
+	}
+
+	for _, t := range q.Storage.GetAll("", "", nil) {
+		if t.Debrid == m.From && strings.EqualFold(t.Hash, m.InfoHash) {
+			t.Debrid = m.To
+			t.ID = m.NewID
+			q.Storage.Update(t)
+		}
+	}
+	q.logger.Info().Msgf("Relinked %d files of %s from %s to %s", relinked, m.InfoHash, m.From, m.To)
+}
+
 func (q *QBit) getTorrentPath(rclonePath string, debridTorrent *debridTypes.Torrent) (string, error) {
 	for {
 		torrentPath, err := debridTorrent.GetMountFolder(rclonePath)
@@ -3,9 +3,12 @@ package qbit
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/internal/utils"
 	"github.com/sirrobot01/decypharr/pkg/arr"
+	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
 	"github.com/sirrobot01/decypharr/pkg/service"
 	"net/http"
 	"path/filepath"
@@ -60,7 +63,7 @@ func (q *QBit) authContext(next http.Handler) http.Handler {
 		a := svc.Arr.Get(category)
 		if a == nil {
 			downloadUncached := false
-			a = arr.New(category, "", "", false, false, &downloadUncached)
+			a = arr.New(category, "", "", false, false, &downloadUncached, nil)
 		}
 		if err == nil {
 			host = strings.TrimSpace(host)
@@ -190,10 +193,11 @@ func (q *QBit) handleTorrentsAdd(w http.ResponseWriter, r *http.Request) {
 		for _, u := range strings.Split(urls, "\n") {
 			urlList = append(urlList, strings.TrimSpace(u))
 		}
+		q.prefetchAvailability(ctx, urlList)
 		for _, url := range urlList {
 			if err := q.AddMagnet(ctx, url, category); err != nil {
 				q.logger.Info().Msgf("Error adding magnet: %v", err)
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				writeAddError(w, err)
 				return
 			}
 			atleastOne = true
@@ -206,7 +210,7 @@ func (q *QBit) handleTorrentsAdd(w http.ResponseWriter, r *http.Request) {
 			for _, fileHeader := range files {
 				if err := q.AddTorrent(ctx, fileHeader, category); err != nil {
 					q.logger.Info().Msgf("Error adding torrent: %v", err)
-					http.Error(w, err.Error(), http.StatusBadRequest)
+					writeAddError(w, err)
 					return
 				}
 				atleastOne = true
@@ -222,6 +226,38 @@ func (q *QBit) handleTorrentsAdd(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// writeAddError answers a failed add. Torrents refused for not being cached or for infringement get qBittorrent's
+// "Fails.", which arrs treat as a rejected release and move on to the next one. Temporary provider failures get
+// a 503 so the arr retries the same release later.
+func writeAddError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, debrid.ErrNotCached), errors.Is(err, request.InfringingFileError):
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Fails."))
+	case request.IsTemporary(err):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// prefetchAvailability checks all the magnets of a request in one batch, so each add hits the availability cache
+func (q *QBit) prefetchAvailability(ctx context.Context, urls []string) {
+	a, ok := ctx.Value("arr").(*arr.Arr)
+	if !ok || len(urls) < 2 {
+		return
+	}
+	hashes := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if hash := utils.ExtractInfoHash(u); hash != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(hashes) > 1 {
+		service.GetService().Debrid.IsAvailable(hashes, a.Name)
+	}
+}
+
 func (q *QBit) handleTorrentsDelete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	hashes, _ := ctx.Value("hashes").([]string)
@@ -5,6 +5,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/sirrobot01/decypharr/internal/config"
 	"github.com/sirrobot01/decypharr/internal/logger"
+	"github.com/sirrobot01/decypharr/pkg/service"
 	"os"
 	"path/filepath"
 )
@@ -20,6 +21,8 @@ type QBit struct {
 	Tags            []string
 	RefreshInterval int
 	SkipPreCache    bool
+	// Strm writes .strm files instead of symlinks for debrids served over WebDAV
+	Strm bool
 
 	downloadSemaphore chan struct{}
 }
@@ -29,7 +32,7 @@ func New() *QBit {
 	cfg := _cfg.QBitTorrent
 	port := cmp.Or(_cfg.Port, os.Getenv("QBIT_PORT"), "8282")
 	refreshInterval := cmp.Or(cfg.RefreshInterval, 10)
-	return &QBit{
+	q := &QBit{
 		Username:          cfg.Username,
 		Password:          cfg.Password,
 		Port:              port,
@@ -39,8 +42,12 @@ func New() *QBit {
 		logger:            logger.New("qbit"),
 		RefreshInterval:   refreshInterval,
 		SkipPreCache:      cfg.SkipPreCache,
+		Strm:              cfg.Strm,
 		downloadSemaphore: make(chan struct{}, cmp.Or(cfg.MaxDownloads, 5)),
 	}
+	// Repairs can move a torrent to another debrid, keep its symlinks pointing at it
+	service.GetDebrid().OnTorrentMoved(q.relinkMovedTorrent)
+	return q
 }
 
 func (q *QBit) Reset() {
@@ -123,9 +123,13 @@ func (q *QBit) ProcessFiles(torrent *Torrent, debridTorrent *debridTypes.Torrent
 				return
 			}
 
-			rclonePath := filepath.Join(debridTorrent.MountPath, cache.GetTorrentFolder(debridTorrent)) // /mnt/remote/realdebrid/MyTVShow
 			torrentFolderNoExt := utils.RemoveExtension(debridTorrent.Name)
-			torrentSymlinkPath, err = q.createSymlinksWebdav(debridTorrent, rclonePath, torrentFolderNoExt) // /mnt/symlinks/{category}/MyTVShow/
+			if q.Strm {
+				torrentSymlinkPath, err = q.createStrmFiles(cache, debridTorrent, torrentFolderNoExt) // /mnt/symlinks/{category}/MyTVShow/
+			} else {
+				rclonePath := filepath.Join(debridTorrent.MountPath, cache.GetTorrentFolder(debridTorrent))     // /mnt/remote/realdebrid/MyTVShow
+				torrentSymlinkPath, err = q.createSymlinksWebdav(debridTorrent, rclonePath, torrentFolderNoExt) // /mnt/symlinks/{category}/MyTVShow/
+			}
 
 		} else {
 			// User is using either zurg or debrid webdav
@@ -291,8 +295,19 @@ func (q *QBit) GetTorrentFiles(t *Torrent) []*TorrentFile {
 	}
 	for _, file := range t.DebridTorrent.Files {
 		files = append(files, &TorrentFile{
-			Name: file.Path,
-			Size: file.Size,
+			Index:    len(files),
+			Name:     file.Path,
+			Size:     file.Size,
+			Priority: 1,
+		})
+	}
+	// Skipped files are reported with priority 0, like files set to "do not download" in qBittorrent
+	for _, file := range t.DebridTorrent.SkippedFiles {
+		files = append(files, &TorrentFile{
+			Index:      len(files),
+			Name:       file.Path,
+			Size:       file.Size,
+			SkipReason: file.Reason,
 		})
 	}
 	return files
@@ -287,6 +287,7 @@ type TorrentFile struct {
 	IsSeed       bool    `json:"is_seed,omitempty"`
 	PieceRange   []int   `json:"piece_range,omitempty"`
 	Availability float64 `json:"availability,omitempty"`
+	SkipReason   string  `json:"skip_reason,omitempty"` // Why the file was not selected, not part of the qBittorrent API
 }
 
 func NewAppPreferences() *AppPreferences {
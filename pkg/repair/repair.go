@@ -14,6 +14,7 @@ import (
 	"github.com/sirrobot01/decypharr/pkg/arr"
 	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
 	"golang.org/x/sync/errgroup"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -546,8 +547,14 @@ func (r *Repair) getZurgBrokenFiles(media arr.Content) []arr.ContentFile {
 		}
 
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			r.logger.Debug().Msgf("Failed to get download url for %s", fullURL)
+			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
+			if err := request.DownloadError(resp.StatusCode, body); request.IsTemporary(err) {
+				// Not broken, the check is retried on the next run
+				r.logger.Debug().Msgf("Skipping %s: %s", fullURL, err)
+				continue
+			}
+			r.logger.Debug().Msgf("Failed to get download url for %s", fullURL)
 			brokenFiles = append(brokenFiles, f...)
 			continue
 		}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/internal/utils"
 	"github.com/sirrobot01/decypharr/pkg/arr"
+	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
 	"github.com/sirrobot01/decypharr/pkg/qbit"
 	"github.com/sirrobot01/decypharr/pkg/service"
 	"github.com/sirrobot01/decypharr/pkg/version"
@@ -41,7 +43,7 @@ func (ui *Handler) handleAddContent(w http.ResponseWriter, r *http.Request) {
 
 	_arr := svc.Arr.Get(arrName)
 	if _arr == nil {
-		_arr = arr.New(arrName, "", "", false, false, &downloadUncached)
+		_arr = arr.New(arrName, "", "", false, false, &downloadUncached, nil)
 	}
 
 	// Handle URLs
@@ -185,6 +187,8 @@ func (ui *Handler) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 			Cleanup:          a.Cleanup,
 			SkipRepair:       a.SkipRepair,
 			DownloadUncached: a.DownloadUncached,
+			CheckCached:      a.CheckCached,
+			FileSelection:    a.FileSelection,
 		})
 	}
 	cfg.Arrs = arrCfgs
@@ -243,6 +247,8 @@ func (ui *Handler) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 			Cleanup:          a.Cleanup,
 			SkipRepair:       a.SkipRepair,
 			DownloadUncached: a.DownloadUncached,
+			CheckCached:      a.CheckCached,
+			FileSelection:    a.FileSelection,
 		})
 	}
 	currentConfig.Arrs = updatedConfig.Arrs
@@ -299,3 +305,162 @@ func (ui *Handler) handleDeleteRepairJob(w http.ResponseWriter, r *http.Request)
 	svc.Repair.DeleteJobs(req.IDs)
 	w.WriteHeader(http.StatusOK)
 }
+
+func (ui *Handler) handleGetDebridHealth(w http.ResponseWriter, r *http.Request) {
+	svc := service.GetService()
+	request.JSONResponse(w, svc.Debrid.Health(), http.StatusOK)
+}
+
+func (ui *Handler) handleResetDebridHealth(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	svc := service.GetService()
+	if !svc.Debrid.ResetHealth(name) {
+		http.Error(w, "Debrid not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ui *Handler) handleGetDebridAccounts(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	svc := service.GetService()
+	accounts, ok := svc.Debrid.Accounts(name)
+	if !ok {
+		http.Error(w, "Debrid not found or has no download keys", http.StatusNotFound)
+		return
+	}
+	request.JSONResponse(w, accounts, http.StatusOK)
+}
+
+func (ui *Handler) handleEnableDebridAccount(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	id := chi.URLParam(r, "id")
+	svc := service.GetService()
+	if err := svc.Debrid.EnableAccount(name, id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type trashedTorrentResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Folder    string    `json:"folder"`
+	InfoHash  string    `json:"info_hash"`
+	Bytes     int64     `json:"bytes"`
+	Files     int       `json:"files"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+func (ui *Handler) handleGetDebridTrash(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	svc := service.GetService()
+	cache, ok := svc.Debrid.Caches[name]
+	if !ok {
+		http.Error(w, "Debrid not found or WebDAV is disabled", http.StatusNotFound)
+		return
+	}
+	trash := cache.GetTrash()
+	resp := make([]trashedTorrentResponse, 0, len(trash))
+	for _, t := range trash {
+		resp = append(resp, trashedTorrentResponse{
+			ID:        t.Id,
+			Name:      t.Name,
+			Folder:    t.Folder,
+			InfoHash:  t.InfoHash,
+			Bytes:     t.Bytes,
+			Files:     len(t.Files),
+			TrashedAt: t.TrashedAt,
+		})
+	}
+	request.JSONResponse(w, resp, http.StatusOK)
+}
+
+func (ui *Handler) handleRestoreTrashedTorrent(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	id := chi.URLParam(r, "id")
+	svc := service.GetService()
+	cache, ok := svc.Debrid.Caches[name]
+	if !ok {
+		http.Error(w, "Debrid not found or WebDAV is disabled", http.StatusNotFound)
+		return
+	}
+	restored, err := cache.RestoreTorrent(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, debrid.ErrTrashedTorrentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	resp := map[string]string{"id": id}
+	if restored != nil {
+		resp["id"] = restored.Id
+		resp["folder"] = cache.GetTorrentFolder(restored.Torrent)
+	}
+	request.JSONResponse(w, resp, http.StatusOK)
+}
+
+func (ui *Handler) handleDeleteTrashedTorrent(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	id := chi.URLParam(r, "id")
+	svc := service.GetService()
+	cache, ok := svc.Debrid.Caches[name]
+	if !ok {
+		http.Error(w, "Debrid not found or WebDAV is disabled", http.StatusNotFound)
+		return
+	}
+	if err := cache.DeleteTrashedTorrent(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, debrid.ErrTrashedTorrentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ui *Handler) handleGetDebridScan(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	svc := service.GetService()
+	cache, ok := svc.Debrid.Caches[name]
+	if !ok {
+		http.Error(w, "Debrid not found or WebDAV is disabled", http.StatusNotFound)
+		return
+	}
+	request.JSONResponse(w, cache.GetScanState(), http.StatusOK)
+}
+
+func (ui *Handler) handleGetReinsertFailures(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	svc := service.GetService()
+	cache, ok := svc.Debrid.Caches[name]
+	if !ok {
+		http.Error(w, "Debrid not found or WebDAV is disabled", http.StatusNotFound)
+		return
+	}
+	request.JSONResponse(w, cache.GetReinsertFailures(), http.StatusOK)
+}
+
+func (ui *Handler) handleResetReinsertFailure(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	id := chi.URLParam(r, "id")
+	svc := service.GetService()
+	cache, ok := svc.Debrid.Caches[name]
+	if !ok {
+		http.Error(w, "Debrid not found or WebDAV is disabled", http.StatusNotFound)
+		return
+	}
+	if err := cache.ResetReinsertFailure(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, debrid.ErrReinsertFailureNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
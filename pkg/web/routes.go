@@ -34,6 +34,16 @@ func (ui *Handler) Routes() http.Handler {
 			r.Delete("/torrents/", ui.handleDeleteTorrents)
 			r.Get("/config", ui.handleGetConfig)
 			r.Post("/config", ui.handleUpdateConfig)
+			r.Get("/debrids/health", ui.handleGetDebridHealth)
+			r.Post("/debrids/{name}/health/reset", ui.handleResetDebridHealth)
+			r.Get("/debrids/{name}/accounts", ui.handleGetDebridAccounts)
+			r.Post("/debrids/{name}/accounts/{id}/enable", ui.handleEnableDebridAccount)
+			r.Get("/debrids/{name}/trash", ui.handleGetDebridTrash)
+			r.Post("/debrids/{name}/trash/{id}/restore", ui.handleRestoreTrashedTorrent)
+			r.Delete("/debrids/{name}/trash/{id}", ui.handleDeleteTrashedTorrent)
+			r.Get("/debrids/{name}/scan", ui.handleGetDebridScan)
+			r.Get("/debrids/{name}/reinsert", ui.handleGetReinsertFailures)
+			r.Post("/debrids/{name}/reinsert/{id}/reset", ui.handleResetReinsertFailure)
 		})
 	})
 
@@ -0,0 +1,359 @@
+package webdav
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/sirrobot01/decypharr/internal/config"
+	"github.com/sirrobot01/decypharr/internal/request"
+	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
+)
+
+// maxChunkPrefetches bounds the read-ahead fetches running at once for a debrid
+const maxChunkPrefetches = 4
+
+// chunkKey is a chunk of a file, files are keyed by their link which doesn't change with the download link
+type chunkKey struct {
+	link  string
+	index int64
+}
+
+type chunkEntry struct {
+	key  chunkKey
+	data []byte
+	size int64 // on disk, data is nil
+}
+
+// chunkFetch is a fetch in flight, readers of the same chunk wait on it instead of fetching again
+type chunkFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// chunkCache streams files in fixed-size chunks, kept in an LRU in memory and optionally on disk. It is
+// shared by the handles of a debrid, so players probing the header and the tail of a file, or reading it
+// at the same time, don't go back to the debrid for every seek.
+type chunkCache struct {
+	cache     *debrid.Cache
+	logger    zerolog.Logger
+	chunkSize int64
+	readAhead int64 // in chunks
+
+	mu       sync.Mutex
+	memory   *list.List // of *chunkEntry, most recent first
+	memIndex map[chunkKey]*list.Element
+	memSize  int64
+	memMax   int64
+	disk     *list.List
+	dskIndex map[chunkKey]*list.Element
+	diskSize int64
+	diskMax  int64
+	diskDir  string
+	inFlight map[chunkKey]*chunkFetch
+
+	prefetches chan struct{}
+}
+
+// newChunkCache returns the chunk cache of a debrid, nil when streaming without chunks
+func newChunkCache(cache *debrid.Cache, dir string) *chunkCache {
+	dc := cache.GetConfig()
+	chunkSize, _ := config.ParseSize(dc.StreamChunkSize)
+	if chunkSize <= 0 {
+		return nil
+	}
+	readAhead, _ := config.ParseSize(dc.StreamReadAhead)
+	memMax, _ := config.ParseSize(dc.StreamCacheSize)
+	diskMax, _ := config.ParseSize(dc.StreamDiskCacheSize)
+
+	cc := &chunkCache{
+		cache:      cache,
+		logger:     cache.GetLogger(),
+		chunkSize:  chunkSize,
+		readAhead:  (max(readAhead, 0) + chunkSize - 1) / chunkSize,
+		memory:     list.New(),
+		memIndex:   make(map[chunkKey]*list.Element),
+		memMax:     memMax,
+		disk:       list.New(),
+		dskIndex:   make(map[chunkKey]*list.Element),
+		inFlight:   make(map[chunkKey]*chunkFetch),
+		prefetches: make(chan struct{}, maxChunkPrefetches),
+	}
+	if diskMax > 0 {
+		// the chunks of the last run are dropped, their files may have changed since
+		if err := os.RemoveAll(dir); err != nil {
+			cc.logger.Error().Err(err).Msg("Failed to clear the chunk cache")
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			cc.logger.Error().Err(err).Msg("Failed to create the chunk cache, chunks are kept in memory only")
+		} else {
+			cc.diskDir = dir
+			cc.diskMax = diskMax
+		}
+	}
+	return cc
+}
+
+// get returns a chunk of a file, from the cache, a fetch in flight, or the debrid
+func (cc *chunkCache) get(f *File, index int64) ([]byte, error) {
+	key := chunkKey{f.link, index}
+	if data := cc.lookup(key); data != nil {
+		return data, nil
+	}
+	cc.mu.Lock()
+	if fetch, ok := cc.inFlight[key]; ok {
+		cc.mu.Unlock()
+		<-fetch.done
+		return fetch.data, fetch.err
+	}
+	fetch := &chunkFetch{done: make(chan struct{})}
+	cc.inFlight[key] = fetch
+	cc.mu.Unlock()
+
+	cc.fetch(f, key, fetch)
+	return fetch.data, fetch.err
+}
+
+// read reads a file from its offset through the chunks. Sequential reads fetch the next chunks ahead.
+func (cc *chunkCache) read(f *File, p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	index := f.offset / cc.chunkSize
+	// reading on from the last chunk is a stream, not a probe
+	sequential := f.chunk != nil && (index == f.chunkIndex || index == f.chunkIndex+1)
+	if f.chunk == nil || f.chunkIndex != index {
+		data, err := cc.get(f, index)
+		if err != nil {
+			return 0, err
+		}
+		f.chunk, f.chunkIndex = data, index
+	}
+	if sequential {
+		cc.prefetch(f, index)
+	}
+	off := f.offset - index*cc.chunkSize
+	if off >= int64(len(f.chunk)) {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(int64(len(p)), f.size-f.offset)], f.chunk[off:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+// prefetch fetches the chunks after index in the background. It is skipped when too many are running.
+func (cc *chunkCache) prefetch(f *File, index int64) {
+	for i := index + 1; i <= index+cc.readAhead && i*cc.chunkSize < f.size; i++ {
+		key := chunkKey{f.link, i}
+		cc.mu.Lock()
+		_, inMemory := cc.memIndex[key]
+		_, onDisk := cc.dskIndex[key]
+		_, inFlight := cc.inFlight[key]
+		if inMemory || onDisk || inFlight {
+			cc.mu.Unlock()
+			continue
+		}
+		select {
+		case cc.prefetches <- struct{}{}:
+		default:
+			cc.mu.Unlock()
+			return
+		}
+		fetch := &chunkFetch{done: make(chan struct{})}
+		cc.inFlight[key] = fetch
+		cc.mu.Unlock()
+
+		go func() {
+			defer func() { <-cc.prefetches }()
+			cc.fetch(f, key, fetch)
+			if fetch.err != nil {
+				cc.logger.Trace().Err(fetch.err).Msgf("Failed to read ahead %s", f.name)
+			}
+		}()
+	}
+}
+
+// fetch fetches a chunk from the debrid, stores it and wakes the readers waiting on it
+func (cc *chunkCache) fetch(f *File, key chunkKey, fetch *chunkFetch) {
+	start := key.index * cc.chunkSize
+	end := min(start+cc.chunkSize, f.size) - 1
+	fetch.data, fetch.err = fetchRange(cc.cache, f.torrentName, f.name, f.link, start, end, f.size)
+
+	cc.mu.Lock()
+	delete(cc.inFlight, key)
+	if fetch.err == nil {
+		cc.storeInMemory(key, fetch.data)
+	}
+	cc.mu.Unlock()
+	close(fetch.done)
+
+	if fetch.err == nil {
+		cc.storeOnDisk(key, fetch.data)
+	}
+}
+
+// lookup returns a cached chunk and marks it as used, a chunk read from disk goes back to memory
+func (cc *chunkCache) lookup(key chunkKey) []byte {
+	cc.mu.Lock()
+	if el, ok := cc.memIndex[key]; ok {
+		cc.memory.MoveToFront(el)
+		cc.mu.Unlock()
+		return el.Value.(*chunkEntry).data
+	}
+	el, ok := cc.dskIndex[key]
+	if !ok {
+		cc.mu.Unlock()
+		return nil
+	}
+	cc.disk.MoveToFront(el)
+	cc.mu.Unlock()
+
+	data, err := os.ReadFile(cc.chunkPath(key))
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if err != nil {
+		// evicted meanwhile, or gone from the disk
+		if el, ok := cc.dskIndex[key]; ok {
+			cc.removeFromDisk(el)
+		}
+		return nil
+	}
+	cc.storeInMemory(key, data)
+	return data
+}
+
+// storeOnDisk writes a chunk to the disk cache, if it's enabled
+func (cc *chunkCache) storeOnDisk(key chunkKey, data []byte) {
+	if cc.diskMax <= 0 || int64(len(data)) > cc.diskMax {
+		return
+	}
+	cc.mu.Lock()
+	_, exists := cc.dskIndex[key]
+	cc.mu.Unlock()
+	if exists {
+		return
+	}
+	if err := os.WriteFile(cc.chunkPath(key), data, 0644); err != nil {
+		cc.logger.Debug().Err(err).Msg("Failed to write chunk to disk")
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if _, ok := cc.dskIndex[key]; ok {
+		return
+	}
+	cc.dskIndex[key] = cc.disk.PushFront(&chunkEntry{key: key, size: int64(len(data))})
+	cc.diskSize += int64(len(data))
+	for cc.diskSize > cc.diskMax {
+		cc.removeFromDisk(cc.disk.Back())
+	}
+}
+
+// storeInMemory keeps a chunk in memory, evicting the least recently used ones. cc.mu must be held.
+func (cc *chunkCache) storeInMemory(key chunkKey, data []byte) {
+	if cc.memMax <= 0 || int64(len(data)) > cc.memMax {
+		return
+	}
+	if el, ok := cc.memIndex[key]; ok {
+		cc.memory.MoveToFront(el)
+		return
+	}
+	cc.memIndex[key] = cc.memory.PushFront(&chunkEntry{key: key, data: data})
+	cc.memSize += int64(len(data))
+	for cc.memSize > cc.memMax {
+		el := cc.memory.Back()
+		e := el.Value.(*chunkEntry)
+		cc.memory.Remove(el)
+		delete(cc.memIndex, e.key)
+		cc.memSize -= int64(len(e.data))
+	}
+}
+
+// removeFromDisk drops a chunk from the disk cache. cc.mu must be held.
+func (cc *chunkCache) removeFromDisk(el *list.Element) {
+	e := el.Value.(*chunkEntry)
+	cc.disk.Remove(el)
+	delete(cc.dskIndex, e.key)
+	cc.diskSize -= e.size
+	_ = os.Remove(cc.chunkPath(e.key))
+}
+
+func (cc *chunkCache) chunkPath(key chunkKey) string {
+	sum := sha1.Sum([]byte(key.link))
+	return filepath.Join(cc.diskDir, fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), key.index))
+}
+
+// fetchRange reads bytes start to end, inclusive, of a file of size bytes from the debrid. A link the debrid
+// refuses is marked as invalid and the range is fetched again with a new one.
+func fetchRange(cache *debrid.Cache, torrentName, filename, fileLink string, start, end, size int64) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		downloadLink, err := cache.GetDownloadLink(torrentName, filename, fileLink)
+		if err != nil {
+			return nil, err
+		}
+		if downloadLink == "" || !isValidURL(downloadLink) {
+			return nil, os.ErrNotExist
+		}
+		req, err := http.NewRequest("GET", downloadLink, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		resp, err := sharedClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent, http.StatusOK:
+			data, err := readRange(resp, start, end, size)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			cache.RecordUsage(fileLink, int64(len(data)))
+			return data, nil
+		}
+
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		lastErr = request.DownloadError(resp.StatusCode, b)
+		if !errors.Is(lastErr, request.TrafficExceededError) && !errors.Is(lastErr, request.ErrLinkBroken) {
+			return nil, lastErr
+		}
+		cache.MarkDownloadLinkAsInvalid(fileLink, downloadLink, lastErr)
+	}
+	return nil, lastErr
+}
+
+// readRange reads the range out of a response, a server that ignored the range sends the whole file. The range
+// may come short only at the end of the file, which can be smaller than the debrid reported. A range cut short
+// elsewhere is an error, it must not be cached.
+func readRange(resp *http.Response, start, end, size int64) ([]byte, error) {
+	body := io.Reader(resp.Body)
+	if resp.StatusCode == http.StatusOK && start > 0 {
+		if _, err := io.CopyN(io.Discard, body, start); err != nil {
+			return nil, err
+		}
+	}
+	data := make([]byte, end-start+1)
+	n, err := io.ReadFull(body, data)
+	if errors.Is(err, io.ErrUnexpectedEOF) && end < size-1 {
+		return nil, fmt.Errorf("range %d-%d cut short after %d bytes: %w", start, end, n, err)
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return data[:n], nil
+}
@@ -2,12 +2,13 @@ package webdav
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"github.com/sirrobot01/decypharr/internal/request"
 	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
 	"io"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -45,6 +46,14 @@ type File struct {
 
 	downloadLink string
 	link         string
+
+	// bytesRead is streamed data not yet recorded against the download key
+	bytesRead int64
+
+	// chunks streams the file through the shared chunk cache, nil streams it in a single request
+	chunks     *chunkCache
+	chunk      []byte
+	chunkIndex int64
 }
 
 // File interface implementations for File
@@ -54,9 +63,17 @@ func (f *File) Close() error {
 		f.reader.Close()
 		f.reader = nil
 	}
+	f.recordUsage()
 	return nil
 }
 
+func (f *File) recordUsage() {
+	if f.bytesRead > 0 {
+		f.cache.RecordUsage(f.link, f.bytesRead)
+		f.bytesRead = 0
+	}
+}
+
 func (f *File) getDownloadLink() (string, error) {
 	// Check if we already have a final URL cached
 
@@ -115,28 +132,19 @@ func (f *File) stream() (*http.Response, error) {
 			resp.Body.Close()
 		}
 
-		if resp.StatusCode == http.StatusServiceUnavailable {
-			b, _ := io.ReadAll(resp.Body)
-			err := resp.Body.Close()
-			if err != nil {
-				_log.Trace().Msgf("Failed to close response body: %s", err)
-				return nil, io.EOF
-			}
-			if strings.Contains(string(b), "You can not download this file because you have exceeded your traffic on this hoster") {
-				_log.Trace().Msgf("Bandwidth exceeded for %s. Download token will be disabled if you have more than one", f.name)
-				f.cache.MarkDownloadLinkAsInvalid(f.link, downloadLink, "bandwidth_exceeded")
-				// Retry with a different API key if it's available
-				return f.stream()
-			} else {
-				_log.Trace().Msgf("Failed to get download link for %s. %s", f.name, string(b))
-				return resp, io.EOF
-			}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		streamErr := request.DownloadError(resp.StatusCode, b)
 
-		} else if resp.StatusCode == http.StatusNotFound {
-			closeResp()
+		if errors.Is(streamErr, request.TrafficExceededError) {
+			_log.Trace().Msgf("Bandwidth exceeded for %s. Download token will be disabled if you have more than one", f.name)
+			f.cache.MarkDownloadLinkAsInvalid(f.link, downloadLink, streamErr)
+			// Retry with a different API key if it's available
+			return f.stream()
+		} else if errors.Is(streamErr, request.ErrLinkBroken) {
 			// Mark download link as not found
 			// Regenerate a new download link
-			f.cache.MarkDownloadLinkAsInvalid(f.link, downloadLink, "link_not_found")
+			f.cache.MarkDownloadLinkAsInvalid(f.link, downloadLink, streamErr)
 			// Generate a new download link
 			downloadLink, err = f.getDownloadLink()
 			if err != nil {
@@ -162,13 +170,13 @@ func (f *File) stream() (*http.Response, error) {
 			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 				closeResp()
 				// Read the body to consume the response
-				f.cache.MarkDownloadLinkAsInvalid(f.link, downloadLink, "link_not_found")
+				f.cache.MarkDownloadLinkAsInvalid(f.link, downloadLink, request.ErrLinkBroken)
 				return resp, io.EOF
 			}
 			return resp, nil
 
 		} else {
-			closeResp()
+			_log.Trace().Msgf("Failed to stream %s. %s: %s", f.name, streamErr, string(b))
 			return resp, io.EOF
 		}
 
@@ -192,12 +200,17 @@ func (f *File) Read(p []byte) (n int, err error) {
 		return n, nil
 	}
 
+	if f.chunks != nil {
+		return f.chunks.read(f, p)
+	}
+
 	// If we haven't started streaming the file yet or need to reposition
 	if f.reader == nil || f.seekPending {
 		if f.reader != nil && f.seekPending {
 			f.reader.Close()
 			f.reader = nil
 		}
+		f.recordUsage()
 
 		// Make the request to get the file
 		resp, err := f.stream()
@@ -214,6 +227,7 @@ func (f *File) Read(p []byte) (n int, err error) {
 
 	n, err = f.reader.Read(p)
 	f.offset += int64(n)
+	f.bytesRead += int64(n)
 
 	if err != nil {
 		f.reader.Close()
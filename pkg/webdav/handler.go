@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/sirrobot01/decypharr/internal/config"
 	"github.com/sirrobot01/decypharr/internal/utils"
 	"github.com/sirrobot01/decypharr/pkg/debrid/debrid"
 	"github.com/sirrobot01/decypharr/pkg/debrid/types"
@@ -25,6 +26,7 @@ type Handler struct {
 	Name     string
 	logger   zerolog.Logger
 	cache    *debrid.Cache
+	chunks   *chunkCache
 	URLBase  string
 	RootPath string
 }
@@ -33,6 +35,7 @@ func NewHandler(name, urlBase string, cache *debrid.Cache, logger zerolog.Logger
 	h := &Handler{
 		Name:     name,
 		cache:    cache,
+		chunks:   newChunkCache(cache, filepath.Join(config.Get().Path, "cache", name+".chunks")),
 		logger:   logger,
 		URLBase:  urlBase,
 		RootPath: path.Join(urlBase, "webdav", name),
@@ -73,6 +76,10 @@ func (h *Handler) RemoveAll(ctx context.Context, name string) error {
 	}
 
 	torrentName, _ := getName(rootDir, name)
+	if strings.HasPrefix(name, path.Join(rootDir, trashFolder)+"/") {
+		// Deleting from the trash is for good
+		return h.cache.DeleteTrashedTorrent(trashedID(torrentName))
+	}
 	cachedTorrent := h.cache.GetTorrentByName(torrentName)
 	if cachedTorrent == nil {
 		h.logger.Debug().Msgf("Torrent not found: %s", torrentName)
@@ -93,7 +100,7 @@ func (h *Handler) getTorrentsFolders(folder string) []os.FileInfo {
 }
 
 func (h *Handler) getParentItems() []string {
-	parents := []string{"__all__", "torrents", "__bad__"}
+	parents := []string{"__all__", "torrents", "__bad__", trashFolder}
 
 	// Add custom folders
 	parents = append(parents, h.cache.GetCustomFolders()...)
@@ -105,8 +112,9 @@ func (h *Handler) getParentItems() []string {
 
 func (h *Handler) getParentFiles() []os.FileInfo {
 	now := time.Now()
-	rootFiles := make([]os.FileInfo, 0, len(h.getParentItems()))
-	for _, item := range h.getParentItems() {
+	items := append(h.getParentItems(), h.cache.GetLibraryFolders()...)
+	rootFiles := make([]os.FileInfo, 0, len(items))
+	for _, item := range items {
 		f := &FileInfo{
 			name:    item,
 			size:    0,
@@ -136,16 +144,34 @@ func (h *Handler) getChildren(name string) []os.FileInfo {
 	if name == root {
 		return h.getParentFiles()
 	}
+	// the trash is listed from the store
+	if name == path.Join(root, trashFolder) {
+		return h.getTrashFolders()
+	}
+	rel := strings.TrimPrefix(name, root+"/")
+	// the media library (e.g. /root/shows/title/Season 01)
+	if h.cache.IsLibraryPath(rel) {
+		children, _ := h.cache.GetLibraryListing(rel)
+		return children
+	}
 	// one level down (e.g. /root/parentFolder)
 	if parent, ok := h.isParentPath(name); ok {
 		return h.getTorrentsFolders(parent)
 	}
 	// torrent-folder level (e.g. /root/parentFolder/torrentName)
-	rel := strings.TrimPrefix(name, root+"/")
 	parts := strings.Split(rel, "/")
+	if len(parts) == 2 && parts[0] == trashFolder {
+		if t, err := h.cache.GetTrashedTorrent(trashedID(parts[1])); err == nil {
+			return h.getFileInfos(t.Torrent)
+		}
+		return nil
+	}
 	if len(parts) == 2 && utils.Contains(h.getParentItems(), parts[0]) {
 		torrentName := parts[1]
 		if t := h.cache.GetTorrentByName(torrentName); t != nil {
+			if h.cache.IsStrmFolder(parts[0]) {
+				return h.cache.GetStrmListing(torrentName, t)
+			}
 			return h.getFileInfos(t.Torrent)
 		}
 	}
@@ -195,12 +221,48 @@ func (h *Handler) OpenFile(ctx context.Context, name string, flag int, perm os.F
 	// 3) file‐within‐torrent case
 	// everything else must be a file under a torrent folder
 	rel := strings.TrimPrefix(name, rootDir+"/")
+	if h.cache.IsLibraryPath(rel) {
+		if torrentName, filename, ok := h.cache.GetLibraryFile(rel); ok {
+			if cached := h.cache.GetTorrentByName(torrentName); cached != nil {
+				if file, ok := cached.Files[filename]; ok {
+					return &File{
+						cache:        h.cache,
+						torrentName:  torrentName,
+						fileId:       file.Id,
+						isDir:        false,
+						name:         file.Name,
+						size:         file.Size,
+						link:         file.Link,
+						chunks:       h.chunks,
+						metadataOnly: metadataOnly,
+						modTime:      cached.AddedOn,
+					}, nil
+				}
+			}
+		}
+		h.logger.Info().Msgf("File not found: %s", name)
+		return nil, os.ErrNotExist
+	}
 	parts := strings.Split(rel, "/")
 	if len(parts) >= 2 {
 		if utils.Contains(h.getParentItems(), parts[0]) {
 			torrentName := parts[1]
 			cached := h.cache.GetTorrentByName(torrentName)
-			if cached != nil && len(parts) >= 3 {
+			if cached != nil && len(parts) >= 3 && h.cache.IsStrmFolder(parts[0]) {
+				strmName := filepath.Clean(path.Join(parts[2:]...))
+				if filename, ok := h.cache.GetStrmFiles(cached.Torrent)[strmName]; ok {
+					content := []byte(h.cache.StrmURL(torrentName, filename))
+					return &File{
+						cache:        h.cache,
+						isDir:        false,
+						content:      content,
+						name:         strmName,
+						size:         int64(len(content)),
+						metadataOnly: metadataOnly,
+						modTime:      cached.AddedOn,
+					}, nil
+				}
+			} else if cached != nil && len(parts) >= 3 {
 				filename := filepath.Clean(path.Join(parts[2:]...))
 				if file, ok := cached.Files[filename]; ok {
 					return &File{
@@ -211,6 +273,7 @@ func (h *Handler) OpenFile(ctx context.Context, name string, flag int, perm os.F
 						name:         file.Name,
 						size:         file.Size,
 						link:         file.Link,
+						chunks:       h.chunks,
 						metadataOnly: metadataOnly,
 						modTime:      cached.AddedOn,
 					}, nil
@@ -349,25 +412,31 @@ func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, file we
 	parentPath := path.Dir(cleanPath)
 	showParent := cleanPath != "/" && parentPath != "." && parentPath != cleanPath
 	isBadPath := strings.HasSuffix(cleanPath, "__bad__")
+	isTrashPath := strings.HasSuffix(cleanPath, trashFolder)
 	_, canDelete := h.isParentPath(cleanPath)
 
 	// Prepare template data
 	data := struct {
-		Path       string
-		ParentPath string
-		ShowParent bool
-		Children   []os.FileInfo
-		URLBase    string
-		IsBadPath  bool
-		CanDelete  bool
+		Path        string
+		ParentPath  string
+		ShowParent  bool
+		Children    []os.FileInfo
+		URLBase     string
+		IsBadPath   bool
+		CanDelete   bool
+		IsTrashPath bool
+		// RestorePath is the API endpoint trashed torrents are restored with
+		RestorePath string
 	}{
-		Path:       cleanPath,
-		ParentPath: parentPath,
-		ShowParent: showParent,
-		Children:   children,
-		URLBase:    h.URLBase,
-		IsBadPath:  isBadPath,
-		CanDelete:  canDelete,
+		Path:        cleanPath,
+		ParentPath:  parentPath,
+		ShowParent:  showParent,
+		Children:    children,
+		URLBase:     h.URLBase,
+		IsBadPath:   isBadPath,
+		CanDelete:   canDelete,
+		IsTrashPath: isTrashPath,
+		RestorePath: path.Join("/", h.URLBase, "api", "debrids", h.Name, "trash"),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
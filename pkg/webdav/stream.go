@@ -0,0 +1,60 @@
+package webdav
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirrobot01/decypharr/internal/utils"
+)
+
+// handleStream redirects a .strm file to a fresh download link of its file. A torrent a repair moved to
+// another debrid is looked up there.
+func (wd *WebDav) handleStream(w http.ResponseWriter, r *http.Request) {
+	debridName := utils.PathUnescape(chi.URLParam(r, "debrid"))
+	torrentName := utils.PathUnescape(chi.URLParam(r, "torrent"))
+	filename := utils.PathUnescape(chi.URLParam(r, "*"))
+
+	// the debrid of the link goes first
+	handlers := make([]*Handler, 0, len(wd.Handlers))
+	for _, h := range wd.Handlers {
+		if h.Name == debridName {
+			handlers = append([]*Handler{h}, handlers...)
+		} else {
+			handlers = append(handlers, h)
+		}
+	}
+
+	for _, h := range handlers {
+		select {
+		case <-h.cache.IsReady():
+		default:
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "WebDAV service is initializing, please try again shortly", http.StatusServiceUnavailable)
+			return
+		}
+		cached := h.cache.GetTorrentByName(torrentName)
+		if cached == nil {
+			continue
+		}
+		file, ok := cached.Files[filename]
+		if !ok {
+			continue
+		}
+		link, err := h.cache.GetDownloadLink(torrentName, filename, file.Link)
+		if err != nil {
+			h.logger.Debug().
+				Err(err).
+				Str("link", file.Link).
+				Str("path", r.URL.Path).
+				Msg("Could not fetch download link")
+			http.Error(w, "Could not fetch download link", http.StatusPreconditionFailed)
+			return
+		}
+		if link == "" {
+			break
+		}
+		http.Redirect(w, r, link, http.StatusTemporaryRedirect)
+		return
+	}
+	http.NotFound(w, r)
+}
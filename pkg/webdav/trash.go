@@ -0,0 +1,35 @@
+package webdav
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// trashFolder lists the torrents deleted from the cache
+const trashFolder = "__trash__"
+
+// getTrashFolders lists the trashed torrents as "<folder> || <id>", the folder alone isn't unique in the trash
+func (h *Handler) getTrashFolders() []os.FileInfo {
+	trash := h.cache.GetTrash()
+	folders := make([]os.FileInfo, 0, len(trash))
+	for _, t := range trash {
+		folders = append(folders, &FileInfo{
+			id:      t.Id,
+			name:    fmt.Sprintf("%s || %s", t.Folder, t.Id),
+			size:    t.Bytes,
+			mode:    0755 | os.ModeDir,
+			modTime: t.TrashedAt,
+			isDir:   true,
+		})
+	}
+	return folders
+}
+
+// trashedID returns the torrent ID of a trash folder name
+func trashedID(name string) string {
+	if idx := strings.LastIndex(name, " || "); idx >= 0 {
+		return name[idx+len(" || "):]
+	}
+	return name
+}
@@ -109,6 +109,7 @@ func (wd *WebDav) Routes() http.Handler {
 	wr.Use(wd.commonMiddleware)
 
 	wd.setupRootHandler(wr)
+	wr.Get("/stream/{debrid}/{torrent}/*", wd.handleStream)
 	wd.mountHandlers(wr)
 
 	return wr